@@ -0,0 +1,162 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Kinds a ScalingRule can reference in NatsConnectionRef.
+const (
+	NatsConnectionKind        = "NatsConnection"
+	ClusterNatsConnectionKind = "ClusterNatsConnection"
+)
+
+// ConditionReachable reports whether the last probe of a NatsConnection succeeded.
+const ConditionReachable = "Reachable"
+
+// NatsConnectionReference references the NatsConnection or ClusterNatsConnection a ScalingRule looks its
+// consumers up through.
+type NatsConnectionReference struct {
+	// Kind is NatsConnection, in the namespace of the ScalingRule, or ClusterNatsConnection.
+	// +kubebuilder:validation:Enum=NatsConnection;ClusterNatsConnection
+	// +kubebuilder:default=NatsConnection
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// Name of the connection.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// NamespacedSecretReference references a Secret, optionally in another namespace.
+type NamespacedSecretReference struct {
+	// Name of the Secret.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Namespace of the Secret. Defaults to the namespace of a NatsConnection, required by a ClusterNatsConnection.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// NatsConnectionSpec defines how to reach a NATS server. It is shared by NatsConnection and ClusterNatsConnection.
+type NatsConnectionSpec struct {
+	// Backend selects how consumers are looked up. "monitoring" scrapes the HTTP /jsz endpoint at
+	// MonitoringURL, "jetstream" calls the JetStream API at URL.
+	// +kubebuilder:validation:Enum=monitoring;jetstream
+	// +kubebuilder:default=monitoring
+	// +optional
+	Backend NatsBackend `json:"backend,omitempty"`
+
+	// MonitoringURL is the NATS HTTP monitoring endpoint. The monitoring backend requires it or MonitoringURLs.
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +optional
+	MonitoringURL string `json:"monitoringURL,omitempty"`
+
+	// MonitoringURLs are more monitoring endpoints of the same NATS cluster, tried after MonitoringURL when
+	// it fails.
+	// +kubebuilder:validation:items:Pattern=`^https?://`
+	// +optional
+	MonitoringURLs []string `json:"monitoringURLs,omitempty"`
+
+	// Failover selects the order the monitoring endpoints are tried in.
+	// +kubebuilder:validation:Enum=ordered;roundRobin
+	// +kubebuilder:default=ordered
+	// +optional
+	Failover FailoverPolicy `json:"failover,omitempty"`
+
+	// URL is the NATS client URL, required by the jetstream backend.
+	// +kubebuilder:validation:Pattern=`^(nats|tls)://`
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// Account is the NATS account of the streams, used by rules that don't set their own.
+	// +optional
+	Account string `json:"account,omitempty"`
+
+	// AuthSecretRef references a Secret holding the credentials of the endpoint, with the keys read from
+	// a ScalingRule's natsAuthSecretRef.
+	// +optional
+	AuthSecretRef *NamespacedSecretReference `json:"authSecretRef,omitempty"`
+
+	// ProbeIntervalSeconds is how often the server is probed to update the status.
+	// +kubebuilder:validation:Minimum=5
+	// +kubebuilder:default=60
+	// +optional
+	ProbeIntervalSeconds int32 `json:"probeIntervalSeconds,omitempty"`
+}
+
+// MonitoringEndpoints returns MonitoringURL followed by MonitoringURLs.
+func (s NatsConnectionSpec) MonitoringEndpoints() []string {
+	var urls []string
+	if s.MonitoringURL != "" {
+		urls = append(urls, s.MonitoringURL)
+	}
+	return append(urls, s.MonitoringURLs...)
+}
+
+// NatsConnectionStatus defines the observed state of NatsConnection and ClusterNatsConnection.
+type NatsConnectionStatus struct {
+	// ServerVersion is the version of the NATS server reported on the last successful probe.
+	// +optional
+	ServerVersion string `json:"serverVersion,omitempty"`
+
+	// LastProbeTime is the last time the server was probed.
+	// +optional
+	LastProbeTime *metav1.Time `json:"lastProbeTime,omitempty"`
+
+	// ObservedGeneration is the most recent generation observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations of the connection's state.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Backend",type=string,JSONPath=`.spec.backend`
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.serverVersion`
+// +kubebuilder:printcolumn:name="Reachable",type=string,JSONPath=`.status.conditions[?(@.type=="Reachable")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NatsConnection is the Schema for the natsconnections API. It describes a NATS server that
+// ScalingRules in its namespace can reference instead of repeating the endpoint and credentials.
+type NatsConnection struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NatsConnectionSpec   `json:"spec,omitempty"`
+	Status NatsConnectionStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NatsConnectionList contains a list of NatsConnection.
+type NatsConnectionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NatsConnection `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NatsConnection{}, &NatsConnectionList{})
+}
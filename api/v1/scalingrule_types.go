@@ -17,14 +17,313 @@ limitations under the License.
 package v1
 
 import (
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// NatsBackend is the way pending messages are looked up in NATS.
+type NatsBackend string
+
+const (
+	// NatsBackendMonitoring queries the HTTP monitoring endpoint (/jsz).
+	NatsBackendMonitoring NatsBackend = "monitoring"
+	// NatsBackendJetStream queries the JetStream API over the NATS protocol.
+	NatsBackendJetStream NatsBackend = "jetstream"
+)
+
+// FailoverPolicy is the order in which multiple NATS monitoring endpoints are tried.
+type FailoverPolicy string
+
+const (
+	// FailoverPolicyOrdered tries the endpoints in the listed order, falling back to the next one on failure.
+	FailoverPolicyOrdered FailoverPolicy = "ordered"
+	// FailoverPolicyRoundRobin starts each lookup at the next endpoint, spreading the load across them.
+	FailoverPolicyRoundRobin FailoverPolicy = "roundRobin"
+)
+
+// Keys read from the Secret referenced by NatsAuthSecretRef, all optional.
+const (
+	// AuthSecretCAKey holds a PEM CA bundle to verify the server certificate with.
+	AuthSecretCAKey = "ca.crt"
+	// AuthSecretCertKey and AuthSecretKeyKey hold a PEM client certificate and key, for mutual TLS.
+	AuthSecretCertKey = "tls.crt"
+	AuthSecretKeyKey  = "tls.key"
+	// AuthSecretTokenKey holds a bearer token for the monitoring endpoint, or an auth token for jetstream.
+	AuthSecretTokenKey = "token"
+	// AuthSecretUsernameKey and AuthSecretPasswordKey hold basic auth or user credentials.
+	AuthSecretUsernameKey = "username"
+	AuthSecretPasswordKey = "password"
+)
+
+// SecretReference references a Secret in the namespace of the ScalingRule.
+type SecretReference struct {
+	// Name of the Secret.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// ScaleTargetRef identifies a workload exposing the scale subresource.
+type ScaleTargetRef struct {
+	// +kubebuilder:default="apps/v1"
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
+
+	// +kubebuilder:default=Deployment
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// ScalingPolicy is the algorithm used to compute the desired replica count.
+type ScalingPolicy string
+
+const (
+	// ScalingPolicyStep moves the replicas by one when a threshold is crossed.
+	ScalingPolicyStep ScalingPolicy = "step"
+	// ScalingPolicyProportional sizes the replicas proportionally to the pending messages.
+	ScalingPolicyProportional ScalingPolicy = "proportional"
+	// ScalingPolicyThroughput sizes the replicas proportionally to the rate messages come in.
+	ScalingPolicyThroughput ScalingPolicy = "throughput"
+)
+
+// ScalingMetric is the consumer metric that drives the scaler.
+type ScalingMetric string
+
+const (
+	// ScalingMetricPending uses the messages not yet delivered to the consumer.
+	ScalingMetricPending ScalingMetric = "pending"
+	// ScalingMetricAckPending uses the messages delivered but not yet acknowledged.
+	ScalingMetricAckPending ScalingMetric = "ackPending"
+	// ScalingMetricRedelivered uses the messages that were redelivered.
+	ScalingMetricRedelivered ScalingMetric = "redelivered"
+	// ScalingMetricWaiting uses the pull requests waiting for messages.
+	ScalingMetricWaiting ScalingMetric = "waiting"
+	// ScalingMetricWeighted uses the sum of all of the above, multiplied by MetricWeights.
+	ScalingMetricWeighted ScalingMetric = "weighted"
+)
+
+// MetricWeights are the multipliers of each consumer metric in the weighted sum.
+type MetricWeights struct {
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Pending int32 `json:"pending,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	AckPending int32 `json:"ackPending,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Redelivered int32 `json:"redelivered,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Waiting int32 `json:"waiting,omitempty"`
+}
+
+// ScalingTrigger is a consumer the rule scales on. Fields left empty are inherited from the rule.
+type ScalingTrigger struct {
+	// Name identifies the trigger in the status.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Account is the NATS account the stream belongs to, defaults to the rule's account.
+	// +optional
+	Account string `json:"account,omitempty"`
+
+	// +kubebuilder:validation:MinLength=1
+	StreamName string `json:"streamName"`
+
+	// +kubebuilder:validation:MinLength=1
+	ConsumerName string `json:"consumerName"`
+
+	// +kubebuilder:validation:Enum=pending;ackPending;redelivered;waiting;weighted
+	// +optional
+	Metric ScalingMetric `json:"metric,omitempty"`
+
+	// +optional
+	MetricWeights *MetricWeights `json:"metricWeights,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TargetPendingPerReplica int `json:"targetPendingPerReplica,omitempty"`
+
+	// +optional
+	TargetRatePerReplica *resource.Quantity `json:"targetRatePerReplica,omitempty"`
+
+	// ScaleUpThreshold and ScaleDownThreshold are inherited from the rule when both are omitted.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ScaleUpThreshold int `json:"scaleUpThreshold,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ScaleDownThreshold int `json:"scaleDownThreshold,omitempty"`
+}
+
+// ScalingBehavior configures the scaling behavior in each direction.
+type ScalingBehavior struct {
+	// +optional
+	ScaleUp *StabilizationPolicy `json:"scaleUp,omitempty"`
+	// +optional
+	ScaleDown *StabilizationPolicy `json:"scaleDown,omitempty"`
+}
+
+// StabilizationPolicy configures the stabilization window of one scaling direction.
+type StabilizationPolicy struct {
+	// StabilizationWindowSeconds is the number of seconds for which past recommendations are considered.
+	// Scaling up uses the lowest recommendation within the window, scaling down the highest one, so a
+	// short burst doesn't cause replicas to be dropped right after it. Defaults to 0 (no stabilization).
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=3600
+	// +optional
+	StabilizationWindowSeconds *int32 `json:"stabilizationWindowSeconds,omitempty"`
+}
+
+// PredictionModel is how the recent trigger values are projected forward.
+type PredictionModel string
+
+const (
+	// PredictionModelLinear fits a straight line, for a backlog growing at a steady rate.
+	PredictionModelLinear PredictionModel = "linear"
+	// PredictionModelExponential fits an exponential curve, for a backlog growing faster and faster.
+	PredictionModelExponential PredictionModel = "exponential"
+)
+
+// Prediction configures scaling on the trigger values projected from their recent growth.
+type Prediction struct {
+	// +kubebuilder:validation:Enum=linear;exponential
+	// +kubebuilder:default=linear
+	// +optional
+	Model PredictionModel `json:"model,omitempty"`
+
+	// Samples is the number of recent polls the model is fitted over.
+	// +kubebuilder:validation:Minimum=2
+	// +kubebuilder:validation:Maximum=100
+	// +kubebuilder:default=10
+	// +optional
+	Samples int32 `json:"samples,omitempty"`
+
+	// LookAheadSeconds is how far ahead the trigger values are projected.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=60
+	// +optional
+	LookAheadSeconds int32 `json:"lookAheadSeconds,omitempty"`
+}
+
+// ScaleToZero configures scaling the target to and from zero replicas.
+type ScaleToZero struct {
+	// ActivationThreshold is the number of pending messages that wakes the target up from zero replicas.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	// +optional
+	ActivationThreshold int `json:"activationThreshold,omitempty"`
+
+	// ActivationReplicas is the replica count the target is woken up to.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	// +optional
+	ActivationReplicas int32 `json:"activationReplicas,omitempty"`
+
+	// IdleSeconds is how long the consumer must have no pending and no ack-pending messages
+	// before the target is scaled to zero.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=300
+	// +optional
+	IdleSeconds int32 `json:"idleSeconds,omitempty"`
+}
+
+// ScheduleWindow overrides the replica bounds, and optionally the thresholds, of the rule while it is
+// open, e.g. to pre-warm consumers before a known peak.
+type ScheduleWindow struct {
+	// Name identifies the window in the status.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Start is the standard 5-field cron expression, or a descriptor such as @daily, the window opens on.
+	// +kubebuilder:validation:MinLength=1
+	Start string `json:"start"`
+
+	// DurationSeconds is how long the window stays open every time it opens.
+	// +kubebuilder:validation:Minimum=60
+	DurationSeconds int32 `json:"durationSeconds"`
+
+	// TimeZone is the IANA time zone Start is evaluated in.
+	// +kubebuilder:default=UTC
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// MinReplicas replaces the rule's minReplicas while the window is open.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+
+	// MaxReplicas replaces the rule's maxReplicas while the window is open.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+
+	// ScaleUpThreshold and ScaleDownThreshold replace the thresholds of every trigger while the window
+	// is open. They are set together.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ScaleUpThreshold *int `json:"scaleUpThreshold,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ScaleDownThreshold *int `json:"scaleDownThreshold,omitempty"`
+
+	// TargetPendingPerReplica replaces the target of every trigger while the window is open.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TargetPendingPerReplica *int `json:"targetPendingPerReplica,omitempty"`
+}
+
+// FallbackAction is what the scaler does while the NATS metrics are unavailable.
+type FallbackAction string
+
+const (
+	// FallbackActionHold keeps the current replicas.
+	FallbackActionHold FallbackAction = "hold"
+	// FallbackActionReplicas scales the target to Fallback.Replicas.
+	FallbackActionReplicas FallbackAction = "replicas"
+	// FallbackActionMax scales the target to MaxReplicas.
+	FallbackActionMax FallbackAction = "max"
+)
+
+// Fallback configures how the target is scaled while the NATS lookups keep failing.
+type Fallback struct {
+	// Action is "hold" to keep the current replicas, "replicas" to scale to Replicas or "max" to scale
+	// to MaxReplicas.
+	// +kubebuilder:validation:Enum=hold;replicas;max
+	// +kubebuilder:default=hold
+	// +optional
+	Action FallbackAction `json:"action,omitempty"`
+
+	// FailureThreshold is the number of consecutive failed lookups before the action is taken.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=3
+	// +optional
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+
+	// Replicas is the replica count of the "replicas" action, clamped to MinReplicas and MaxReplicas.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+}
+
 // ScalingRuleSpec defines the desired state of ScalingRule.
 type ScalingRuleSpec struct {
-	// +kubebuilder:validation:MinLength=1
-	DeploymentName string `json:"deploymentName"`
+	// DeploymentName is the name of the Deployment to scale.
+	// Deprecated: use ScaleTargetRef, which the webhook populates from this field.
+	// +optional
+	DeploymentName string `json:"deploymentName,omitempty"`
+
+	// ScaleTargetRef points to the workload to scale, any resource exposing the /scale subresource
+	// (Deployment, StatefulSet, Argo Rollout, ...) is supported.
+	// +optional
+	ScaleTargetRef *ScaleTargetRef `json:"scaleTargetRef,omitempty"`
 
+	// Namespace is the namespace of the scale target.
 	// +kubebuilder:validation:MinLength=1
 	Namespace string `json:"namespace"`
 
@@ -34,33 +333,371 @@ type ScalingRuleSpec struct {
 	// +kubebuilder:validation:Minimum=1
 	MaxReplicas int32 `json:"maxReplicas"`
 
+	// NatsConnectionRef references a NatsConnection or ClusterNatsConnection describing the NATS server,
+	// instead of the natsBackend, natsMonitoringURL(s), natsFailover, natsURL and natsAuthSecretRef fields.
+	// +optional
+	NatsConnectionRef *NatsConnectionReference `json:"natsConnectionRef,omitempty"`
+
+	// NatsBackend selects how pending messages are looked up. "monitoring" scrapes the HTTP /jsz
+	// endpoint at NatsMonitoringURL, "jetstream" calls the JetStream API at NatsURL. Ignored when
+	// NatsConnectionRef is set.
+	// +kubebuilder:validation:Enum=monitoring;jetstream
+	// +kubebuilder:default=monitoring
+	// +optional
+	NatsBackend NatsBackend `json:"natsBackend,omitempty"`
+
+	// NatsMonitoringURL is the NATS HTTP monitoring endpoint. The monitoring backend requires it or
+	// NatsMonitoringURLs.
 	// +kubebuilder:validation:Pattern=`^https?://`
-	NatsMonitoringURL string `json:"natsMonitoringURL"`
+	// +optional
+	NatsMonitoringURL string `json:"natsMonitoringURL,omitempty"`
+
+	// NatsMonitoringURLs are more monitoring endpoints of the same NATS cluster, tried after
+	// NatsMonitoringURL when it fails. Failing endpoints are tried last until their backoff expires.
+	// +kubebuilder:validation:items:Pattern=`^https?://`
+	// +optional
+	NatsMonitoringURLs []string `json:"natsMonitoringURLs,omitempty"`
+
+	// NatsFailover selects the order the monitoring endpoints are tried in.
+	// +kubebuilder:validation:Enum=ordered;roundRobin
+	// +kubebuilder:default=ordered
+	// +optional
+	NatsFailover FailoverPolicy `json:"natsFailover,omitempty"`
+
+	// NatsURL is the NATS client URL, required by the jetstream backend.
+	// +kubebuilder:validation:Pattern=`^(nats|tls)://`
+	// +optional
+	NatsURL string `json:"natsURL,omitempty"`
 
+	// NatsAuthSecretRef references a Secret in the rule's namespace holding the credentials of the NATS
+	// endpoint. Changes to the Secret are picked up without restarting the operator.
+	// +optional
+	NatsAuthSecretRef *SecretReference `json:"natsAuthSecretRef,omitempty"`
+
+	// Account is the NATS account the stream belongs to, defaults to the account of the NatsConnection or
+	// to the global account ($G). Only used by the monitoring backend, with jetstream the account comes
+	// from the connection credentials.
+	// +optional
+	Account string `json:"account,omitempty"`
+
+	// StreamName and ConsumerName select the consumer the rule scales on, required unless Triggers is set.
 	// +kubebuilder:validation:MinLength=1
-	StreamName string `json:"streamName"`
+	// +optional
+	StreamName string `json:"streamName,omitempty"`
 
 	// +kubebuilder:validation:MinLength=1
-	ConsumerName string `json:"consumerName"`
+	// +optional
+	ConsumerName string `json:"consumerName,omitempty"`
+
+	// Triggers lists several consumers to scale on, each with its own metric and thresholds.
+	// The target is scaled to the highest replica count recommended across triggers.
+	// Mutually exclusive with StreamName and ConsumerName.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Triggers []ScalingTrigger `json:"triggers,omitempty"`
+
+	// Metric selects the consumer metric the thresholds and TargetPendingPerReplica apply to. For slow
+	// consumers ackPending, or a weighted sum with pending, also counts the messages being worked on.
+	// +kubebuilder:validation:Enum=pending;ackPending;redelivered;waiting;weighted
+	// +kubebuilder:default=pending
+	// +optional
+	Metric ScalingMetric `json:"metric,omitempty"`
+
+	// MetricWeights are the multipliers of the weighted metric, required when Metric is weighted.
+	// +optional
+	MetricWeights *MetricWeights `json:"metricWeights,omitempty"`
+
+	// ScalingPolicy selects the scaling algorithm. "step" moves the replicas by one per cooldown window
+	// when pending crosses ScaleUpThreshold or ScaleDownThreshold, "proportional" sets the replicas to
+	// ceil(pending / TargetPendingPerReplica) and "throughput" to ceil(ingress rate / TargetRatePerReplica),
+	// both clamped to MinReplicas and MaxReplicas.
+	// +kubebuilder:validation:Enum=step;proportional;throughput
+	// +kubebuilder:default=step
+	// +optional
+	ScalingPolicy ScalingPolicy `json:"scalingPolicy,omitempty"`
+
+	// TargetPendingPerReplica is the number of pending messages a single replica is expected to handle,
+	// required by the proportional policy.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TargetPendingPerReplica int `json:"targetPendingPerReplica,omitempty"`
+
+	// TargetRatePerReplica is the number of messages per second a single replica processes, required by
+	// the throughput policy. The ingress rate of the stream is measured between successive polls.
+	// +optional
+	TargetRatePerReplica *resource.Quantity `json:"targetRatePerReplica,omitempty"`
+
+	// ScaleToZero lets the target be scaled to zero replicas once the consumer is idle and woken up
+	// on the first messages. Requires MinReplicas to be 0; without it MinReplicas is a hard floor.
+	// +optional
+	ScaleToZero *ScaleToZero `json:"scaleToZero,omitempty"`
+
+	// Behavior configures separate scale-up and scale-down stabilization windows.
+	// +optional
+	Behavior *ScalingBehavior `json:"behavior,omitempty"`
+
+	// Prediction makes each trigger scale on the higher of its value and the value projected from its
+	// recent growth, so consumers are added while the backlog grows rather than once it crossed the threshold.
+	// +optional
+	Prediction *Prediction `json:"prediction,omitempty"`
 
+	// Fallback configures how the target is scaled while the NATS metrics are unavailable.
+	// Without it the replicas are held.
+	// +optional
+	Fallback *Fallback `json:"fallback,omitempty"`
+
+	// Schedules override the replica bounds and thresholds during recurring time windows. When several
+	// windows are open, the first one in the list applies.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Schedules []ScheduleWindow `json:"schedules,omitempty"`
+
+	// RestoreReplicas is the replica count the target is scaled back to when the rule is deleted. Defaults
+	// to the baseline replicas the target had when the rule first scaled it.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RestoreReplicas *int32 `json:"restoreReplicas,omitempty"`
+
+	// Suspend freezes the target's replicas. NATS is still polled and the status updated, but the target
+	// isn't scaled until Suspend is cleared.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// SuspendedReplicas pins the target to this replica count while the rule is suspended. Without it the
+	// replicas are left as they are.
 	// +kubebuilder:validation:Minimum=0
-	ScaleUpThreshold int `json:"scaleUpThreshold"`
+	// +optional
+	SuspendedReplicas *int32 `json:"suspendedReplicas,omitempty"`
 
+	// ScaleUpThreshold and ScaleDownThreshold are used by the step policy and defaulted by the webhook
+	// when both are omitted.
 	// +kubebuilder:validation:Minimum=0
-	ScaleDownThreshold int `json:"scaleDownThreshold"`
+	// +optional
+	ScaleUpThreshold int `json:"scaleUpThreshold,omitempty"`
 
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ScaleDownThreshold int `json:"scaleDownThreshold,omitempty"`
+
+	// PollIntervalSeconds is defaulted by the webhook when omitted.
 	// +kubebuilder:validation:Minimum=1
-	PollIntervalSeconds int `json:"pollIntervalSeconds"`
+	// +optional
+	PollIntervalSeconds int `json:"pollIntervalSeconds,omitempty"`
+}
+
+// ScaleTarget returns the workload the rule scales, falling back to a Deployment named DeploymentName
+// when ScaleTargetRef is not set.
+func (s *ScalingRuleSpec) ScaleTarget() ScaleTargetRef {
+	if s.ScaleTargetRef != nil {
+		ref := *s.ScaleTargetRef
+		if ref.APIVersion == "" {
+			ref.APIVersion = "apps/v1"
+		}
+		if ref.Kind == "" {
+			ref.Kind = "Deployment"
+		}
+		return ref
+	}
+	return ScaleTargetRef{APIVersion: "apps/v1", Kind: "Deployment", Name: s.DeploymentName}
+}
+
+// EffectiveTriggers returns the triggers of the rule with the rule-level settings filled in. A rule
+// without Triggers has a single trigger, named after its stream and consumer.
+func (s *ScalingRuleSpec) EffectiveTriggers() []ScalingTrigger {
+	triggers := s.Triggers
+	if len(triggers) == 0 {
+		triggers = []ScalingTrigger{{
+			Name:         s.StreamName + "/" + s.ConsumerName,
+			StreamName:   s.StreamName,
+			ConsumerName: s.ConsumerName,
+		}}
+	}
+
+	out := make([]ScalingTrigger, 0, len(triggers))
+	for _, t := range triggers {
+		if t.Account == "" {
+			t.Account = s.Account
+		}
+		if t.Metric == "" {
+			t.Metric = s.Metric
+			if t.MetricWeights == nil {
+				t.MetricWeights = s.MetricWeights
+			}
+		}
+		if t.TargetPendingPerReplica == 0 {
+			t.TargetPendingPerReplica = s.TargetPendingPerReplica
+		}
+		if t.TargetRatePerReplica == nil {
+			t.TargetRatePerReplica = s.TargetRatePerReplica
+		}
+		if t.ScaleUpThreshold == 0 && t.ScaleDownThreshold == 0 {
+			t.ScaleUpThreshold = s.ScaleUpThreshold
+			t.ScaleDownThreshold = s.ScaleDownThreshold
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// ApplySchedule overrides the replica bounds and thresholds of the spec with the ones of the window.
+func (s *ScalingRuleSpec) ApplySchedule(w *ScheduleWindow) {
+	if w.MinReplicas != nil {
+		s.MinReplicas = *w.MinReplicas
+	}
+	if w.MaxReplicas != nil {
+		s.MaxReplicas = *w.MaxReplicas
+	}
+	if s.MinReplicas > 0 {
+		// a pre-warmed target must not be scaled to zero while it is idle
+		s.ScaleToZero = nil
+	}
+	if w.ScaleUpThreshold != nil && w.ScaleDownThreshold != nil {
+		s.ScaleUpThreshold, s.ScaleDownThreshold = *w.ScaleUpThreshold, *w.ScaleDownThreshold
+		for i := range s.Triggers {
+			s.Triggers[i].ScaleUpThreshold = *w.ScaleUpThreshold
+			s.Triggers[i].ScaleDownThreshold = *w.ScaleDownThreshold
+		}
+	}
+	if w.TargetPendingPerReplica != nil {
+		s.TargetPendingPerReplica = *w.TargetPendingPerReplica
+		for i := range s.Triggers {
+			s.Triggers[i].TargetPendingPerReplica = *w.TargetPendingPerReplica
+		}
+	}
+}
+
+// Condition types reported on ScalingRuleStatus.Conditions.
+const (
+	// ConditionReady is true when the rule was evaluated end to end on the last reconcile.
+	ConditionReady = "Ready"
+	// ConditionNatsReachable reports whether the last NATS lookup succeeded.
+	ConditionNatsReachable = "NatsReachable"
+	// ConditionTargetFound reports whether the scale target exists.
+	ConditionTargetFound = "TargetFound"
+	// ConditionScalingActive reports whether the scaler was able to compute a replica recommendation.
+	ConditionScalingActive = "ScalingActive"
+	// ConditionScalingLimited is true when the recommendation was clamped to minReplicas or maxReplicas.
+	ConditionScalingLimited = "ScalingLimited"
+	// ConditionConflict is true when a HorizontalPodAutoscaler or an older ScalingRule scales the same
+	// target, the rule doesn't scale it until the conflict is resolved.
+	ConditionConflict = "Conflict"
+	// ConditionSuspended is true while the rule is suspended and doesn't scale its target.
+	ConditionSuspended = "Suspended"
+)
+
+// TriggerStatus is the last observation of a trigger.
+type TriggerStatus struct {
+	Name string `json:"name"`
+
+	// Pending is the number of pending messages of the consumer.
+	Pending int `json:"pending"`
+
+	// Value is the value of the trigger's metric.
+	Value int `json:"value"`
+
+	// Predicted is the value projected by the prediction, when enabled.
+	// +optional
+	Predicted int `json:"predicted,omitempty"`
+
+	// IngressRate is the rate messages are appended to the stream, in messages per second.
+	// Only measured by the throughput policy.
+	// +optional
+	IngressRate *resource.Quantity `json:"ingressRate,omitempty"`
+
+	// AckRate is the rate the consumer acknowledges messages, in messages per second.
+	// Only measured by the throughput policy.
+	// +optional
+	AckRate *resource.Quantity `json:"ackRate,omitempty"`
+
+	// DesiredReplicas is the replica count recommended by this trigger alone.
+	DesiredReplicas int32 `json:"desiredReplicas"`
 }
 
 // ScalingRuleStatus defines the observed state of ScalingRule.
 type ScalingRuleStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// ScaleTarget is the workload the rule scales, resolved from scaleTargetRef or the deprecated
+	// deploymentName, which the defaulting webhook only migrates while it is enabled.
+	// +optional
+	ScaleTarget *ScaleTargetRef `json:"scaleTarget,omitempty"`
+
+	// LastObservedPending is the number of pending messages seen on the last successful NATS lookup,
+	// summed across triggers.
+	// +optional
+	LastObservedPending int `json:"lastObservedPending"`
+
+	// LastObservedValue is the value of the selected metric on the last successful NATS lookup, from the
+	// trigger that recommended the most replicas, which is what the scaler acted on.
+	// +optional
+	LastObservedValue int `json:"lastObservedValue"`
+
+	// Triggers reports what each trigger observed and recommended on the last reconcile.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Triggers []TriggerStatus `json:"triggers,omitempty"`
+
+	// CurrentReplicas is the replica count of the target as observed by the scaler.
+	// +optional
+	CurrentReplicas int32 `json:"currentReplicas"`
+
+	// DesiredReplicas is the replica count the scaler computed on the last reconcile.
+	// +optional
+	DesiredReplicas int32 `json:"desiredReplicas"`
+
+	// ConsecutiveFailures is the number of polls in a row the NATS metrics couldn't be looked up, reset on
+	// the next successful lookup. Failed reconciles less than a poll interval after the last counted one,
+	// e.g. triggered by a change to the target, aren't counted.
+	// +optional
+	ConsecutiveFailures int32 `json:"consecutiveFailures,omitempty"`
+
+	// LastFailureTime is the time of the last failure counted in ConsecutiveFailures.
+	// +optional
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+
+	// ActiveSchedule is the name of the schedule window applied on the last reconcile, if any.
+	// +optional
+	ActiveSchedule string `json:"activeSchedule,omitempty"`
+
+	// BaselineReplicas is the replica count of the target before the rule first scaled it, restored
+	// when the rule is deleted.
+	// +optional
+	BaselineReplicas *int32 `json:"baselineReplicas,omitempty"`
+
+	// IdleSince is the time since which the consumer has had no pending and no ack-pending messages,
+	// unset while it has work.
+	// +optional
+	IdleSince *metav1.Time `json:"idleSince,omitempty"`
+
+	// LastScaleTime is the last time the scaler changed the replica count of the target.
+	// +optional
+	LastScaleTime *metav1.Time `json:"lastScaleTime,omitempty"`
+
+	// ObservedGeneration is the most recent generation observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations of the rule's state.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Kind",type=string,JSONPath=`.status.scaleTarget.kind`
+// +kubebuilder:printcolumn:name="Target",type=string,JSONPath=`.status.scaleTarget.name`
+// +kubebuilder:printcolumn:name="Pending",type=integer,JSONPath=`.status.lastObservedPending`
+// +kubebuilder:printcolumn:name="Current",type=integer,JSONPath=`.status.currentReplicas`
+// +kubebuilder:printcolumn:name="Desired",type=integer,JSONPath=`.status.desiredReplicas`
+// +kubebuilder:printcolumn:name="Min",type=integer,JSONPath=`.spec.minReplicas`,priority=1
+// +kubebuilder:printcolumn:name="Max",type=integer,JSONPath=`.spec.maxReplicas`,priority=1
+// +kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.status.activeSchedule`,priority=1
+// +kubebuilder:printcolumn:name="Suspended",type=boolean,JSONPath=`.spec.suspend`,priority=1
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // ScalingRule is the Schema for the scalingrules API.
 type ScalingRule struct {
@@ -45,6 +45,7 @@ import (
 
 	scalingv1 "github.com/Av1shay/nats-scaler/api/v1"
 	"github.com/Av1shay/nats-scaler/internal/controller"
+	webhookscalingv1 "github.com/Av1shay/nats-scaler/internal/webhook/v1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -69,6 +70,7 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var natsCacheTTL time.Duration
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -90,6 +92,9 @@ func main() {
 	opts := zap.Options{
 		Development: true,
 	}
+	flag.DurationVar(&natsCacheTTL, "nats-cache-ttl", 5*time.Second,
+		"How long a NATS monitoring /jsz response is shared by the ScalingRules polling the same server and account. "+
+			"Set to 0 to query the server on every poll.")
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
@@ -208,18 +213,51 @@ func main() {
 		os.Exit(1)
 	}
 
-	natsService := nats.NewService(&http.Client{Timeout: 30 * time.Second})
+	natsService := nats.NewService(&http.Client{Timeout: 30 * time.Second}, nats.WithCacheTTL(natsCacheTTL))
+	jetStreamService := nats.NewJetStreamService()
+	defer jetStreamService.Close()
 	sclr := scaler.NewScaler()
 
 	if err := (&controller.ScalingRuleReconciler{
-		Client:      mgr.GetClient(),
-		Scheme:      mgr.GetScheme(),
-		NatsService: natsService,
-		Scaler:      sclr,
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		APIReader:        mgr.GetAPIReader(),
+		NatsService:      natsService,
+		JetStreamService: jetStreamService,
+		Scaler:           sclr,
+		Recorder:         mgr.GetEventRecorderFor("scalingrule-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ScalingRule")
 		os.Exit(1)
 	}
+	if err := (&controller.NatsConnectionReconciler{
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		APIReader:        mgr.GetAPIReader(),
+		NatsService:      natsService,
+		JetStreamService: jetStreamService,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NatsConnection")
+		os.Exit(1)
+	}
+	if err := (&controller.ClusterNatsConnectionReconciler{
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		APIReader:        mgr.GetAPIReader(),
+		NatsService:      natsService,
+		JetStreamService: jetStreamService,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterNatsConnection")
+		os.Exit(1)
+	}
+	// nolint:goconst
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err := webhookscalingv1.SetupScalingRuleWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ScalingRule")
+			os.Exit(1)
+		}
+	}
+	// +kubebuilder:scaffold:builder
 
 	if metricsCertWatcher != nil {
 		setupLog.Info("Adding metrics certificate watcher to manager")
@@ -0,0 +1,88 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	scalingv1 "github.com/Av1shay/nats-scaler/api/v1"
+	"github.com/Av1shay/nats-scaler/internal/nats"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ClusterNatsConnectionReconciler reconciles a ClusterNatsConnection object
+type ClusterNatsConnectionReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// APIReader reads the Secrets holding NATS credentials from the API server, only the metadata of
+	// Secrets is cached. Client is used when it is nil.
+	APIReader client.Reader
+	// NatsService and JetStreamService probe connections using the monitoring and jetstream backends.
+	NatsService      nats.Prober
+	JetStreamService nats.Prober
+}
+
+// +kubebuilder:rbac:groups=scaling.my.domain,resources=clusternatsconnections,verbs=get;list;watch
+// +kubebuilder:rbac:groups=scaling.my.domain,resources=clusternatsconnections/status,verbs=get;update;patch
+
+// Reconcile probes the NATS server described by a ClusterNatsConnection and reports the outcome in its status.
+func (r *ClusterNatsConnectionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var conn scalingv1.ClusterNatsConnection
+	if err := r.Get(ctx, req.NamespacedName, &conn); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			return ctrl.Result{}, nil
+		}
+		logf.FromContext(ctx).Error(err, "failed to get ClusterNatsConnection", "retryIn", errRequeueIntervalLong)
+		return ctrl.Result{RequeueAfter: errRequeueIntervalLong}, nil
+	}
+	base := conn.DeepCopy()
+	probeNatsConnection(ctx, secretReader(r.APIReader, r.Client), r.NatsService, r.JetStreamService, conn.Spec, "", &conn.Status, conn.Generation)
+	if err := r.Status().Patch(ctx, &conn, client.MergeFrom(base)); err != nil {
+		logf.FromContext(ctx).Error(err, "failed to update ClusterNatsConnection status")
+	}
+	return ctrl.Result{RequeueAfter: probeInterval(conn.Spec)}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterNatsConnectionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		// status writes must not trigger a reconcile, probing is driven by RequeueAfter
+		For(&scalingv1.ClusterNatsConnection{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// only the metadata of Secrets is cached, the referenced ones are read from the API server
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+			_, cluster, err := connectionsForSecret(ctx, r.Client, obj)
+			if err != nil {
+				logf.FromContext(ctx).Error(err, "failed to list ClusterNatsConnections of secret", "secret", client.ObjectKeyFromObject(obj))
+				return nil
+			}
+			reqs := make([]reconcile.Request, 0, len(cluster))
+			for _, conn := range cluster {
+				reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&conn)})
+			}
+			return reqs
+		}), builder.OnlyMetadata).
+		Named("clusternatsconnection").
+		Complete(r)
+}
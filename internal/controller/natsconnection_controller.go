@@ -0,0 +1,269 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	scalingv1 "github.com/Av1shay/nats-scaler/api/v1"
+	"github.com/Av1shay/nats-scaler/internal/nats"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const defaultProbeInterval = time.Minute
+
+// Condition reasons set on NatsConnection and ClusterNatsConnection.
+const (
+	reasonServerResponded = "ServerResponded"
+	reasonProbeFailed     = "ProbeFailed"
+)
+
+// NatsConnectionReconciler reconciles a NatsConnection object
+type NatsConnectionReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// APIReader reads the Secrets holding NATS credentials from the API server, only the metadata of
+	// Secrets is cached. Client is used when it is nil.
+	APIReader client.Reader
+	// NatsService and JetStreamService probe connections using the monitoring and jetstream backends.
+	NatsService      nats.Prober
+	JetStreamService nats.Prober
+}
+
+// +kubebuilder:rbac:groups=scaling.my.domain,resources=natsconnections,verbs=get;list;watch
+// +kubebuilder:rbac:groups=scaling.my.domain,resources=natsconnections/status,verbs=get;update;patch
+
+// Reconcile probes the NATS server described by a NatsConnection and reports the outcome in its status.
+func (r *NatsConnectionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var conn scalingv1.NatsConnection
+	if err := r.Get(ctx, req.NamespacedName, &conn); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			return ctrl.Result{}, nil
+		}
+		logf.FromContext(ctx).Error(err, "failed to get NatsConnection", "retryIn", errRequeueIntervalLong)
+		return ctrl.Result{RequeueAfter: errRequeueIntervalLong}, nil
+	}
+	base := conn.DeepCopy()
+	probeNatsConnection(ctx, secretReader(r.APIReader, r.Client), r.NatsService, r.JetStreamService, conn.Spec, conn.Namespace, &conn.Status, conn.Generation)
+	if err := r.Status().Patch(ctx, &conn, client.MergeFrom(base)); err != nil {
+		logf.FromContext(ctx).Error(err, "failed to update NatsConnection status")
+	}
+	return ctrl.Result{RequeueAfter: probeInterval(conn.Spec)}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NatsConnectionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		// status writes must not trigger a reconcile, probing is driven by RequeueAfter
+		For(&scalingv1.NatsConnection{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// only the metadata of Secrets is cached, the referenced ones are read from the API server
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+			namespaced, _, err := connectionsForSecret(ctx, r.Client, obj)
+			if err != nil {
+				logf.FromContext(ctx).Error(err, "failed to list NatsConnections of secret", "secret", client.ObjectKeyFromObject(obj))
+				return nil
+			}
+			reqs := make([]reconcile.Request, 0, len(namespaced))
+			for _, conn := range namespaced {
+				reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&conn)})
+			}
+			return reqs
+		}), builder.OnlyMetadata).
+		Named("natsconnection").
+		Complete(r)
+}
+
+// probeNatsConnection reads the server version of the connection and records the outcome in status.
+// namespace is the namespace of the connection, empty for a ClusterNatsConnection.
+func probeNatsConnection(ctx context.Context, c client.Reader, natsService, jetStreamService nats.Prober,
+	spec scalingv1.NatsConnectionSpec, namespace string, status *scalingv1.NatsConnectionStatus, generation int64) {
+	logger := logf.FromContext(ctx)
+	status.ObservedGeneration = generation
+	status.LastProbeTime = &metav1.Time{Time: time.Now()}
+	setReachable := func(s metav1.ConditionStatus, reason, message string) {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               scalingv1.ConditionReachable,
+			Status:             s,
+			Reason:             reason,
+			Message:            message,
+			ObservedGeneration: generation,
+		})
+	}
+
+	if err := validateNatsConnectionSpec(spec, namespace); err != nil {
+		logger.Error(err, "invalid NATS connection spec")
+		setReachable(metav1.ConditionFalse, reasonInvalidSpec, err.Error())
+		return
+	}
+	auth, err := readNatsAuth(ctx, c, spec.AuthSecretRef, namespace)
+	if err != nil {
+		logger.Error(err, "failed to read NATS credentials")
+		setReachable(metav1.ConditionFalse, reasonAuthSecretUnavailable, err.Error())
+		return
+	}
+
+	prober, urls := natsService, spec.MonitoringEndpoints()
+	if spec.Backend == scalingv1.NatsBackendJetStream {
+		prober, urls = jetStreamService, []string{spec.URL}
+	}
+	// every endpoint is probed, so that a failed node shows up even while the others answer
+	var version string
+	var failed []string
+	var probeErrs []error
+	for _, u := range urls {
+		v, err := prober.ServerVersion(ctx, u, auth)
+		if err != nil {
+			logger.Error(err, "failed to probe NATS server", "url", u)
+			failed = append(failed, u)
+			probeErrs = append(probeErrs, fmt.Errorf("%s: %w", u, err))
+			continue
+		}
+		if version == "" {
+			version = v
+		}
+	}
+	switch {
+	case version == "":
+		setReachable(metav1.ConditionFalse, reasonProbeFailed, errors.Join(probeErrs...).Error())
+	case len(failed) > 0:
+		status.ServerVersion = version
+		setReachable(metav1.ConditionTrue, reasonServerResponded,
+			fmt.Sprintf("NATS server %s responded, unreachable endpoints: %s", version, strings.Join(failed, ", ")))
+	default:
+		status.ServerVersion = version
+		setReachable(metav1.ConditionTrue, reasonServerResponded, fmt.Sprintf("NATS server %s responded", version))
+	}
+}
+
+func probeInterval(spec scalingv1.NatsConnectionSpec) time.Duration {
+	if spec.ProbeIntervalSeconds <= 0 {
+		return defaultProbeInterval
+	}
+	return time.Duration(spec.ProbeIntervalSeconds) * time.Second
+}
+
+// validateNatsConnectionSpec checks what the CRD schema can't. namespace is the namespace of the connection,
+// empty for a ClusterNatsConnection. A ClusterNatsConnection has no namespace to look its auth secret up in,
+// so it must name one, while a NatsConnection must not read secrets of other namespaces.
+func validateNatsConnectionSpec(spec scalingv1.NatsConnectionSpec, namespace string) error {
+	switch spec.Backend {
+	case scalingv1.NatsBackendJetStream:
+		if spec.URL == "" {
+			return errors.New("url is required by the jetstream backend")
+		}
+	default:
+		if len(spec.MonitoringEndpoints()) == 0 {
+			return errors.New("monitoringURL or monitoringURLs is required by the monitoring backend")
+		}
+	}
+	ref := spec.AuthSecretRef
+	switch {
+	case ref == nil:
+	case namespace == "" && ref.Namespace == "":
+		return errors.New("authSecretRef.namespace is required by a ClusterNatsConnection")
+	case namespace != "" && ref.Namespace != "" && ref.Namespace != namespace:
+		return fmt.Errorf("authSecretRef.namespace must be the namespace of the NatsConnection (%s)", namespace)
+	}
+	return nil
+}
+
+// secretReader returns the reader Secrets are read with, apiReader unless it is nil. Secrets must not be
+// read through the cached client, which would cache the whole of them on top of their metadata.
+func secretReader(apiReader, c client.Reader) client.Reader {
+	if apiReader != nil {
+		return apiReader
+	}
+	return c
+}
+
+// readNatsAuth reads the NATS credentials from the referenced Secret, nil when there is no reference.
+// The secret is looked up in defaultNamespace when the reference doesn't name a namespace.
+func readNatsAuth(ctx context.Context, c client.Reader, ref *scalingv1.NamespacedSecretReference, defaultNamespace string) (*nats.Auth, error) {
+	if ref == nil {
+		return nil, nil
+	}
+	key := types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}
+	if key.Namespace == "" {
+		key.Namespace = defaultNamespace
+	}
+	var secret corev1.Secret
+	if err := c.Get(ctx, key, &secret); err != nil {
+		return nil, fmt.Errorf("failed to get NATS auth secret %s: %w", key, err)
+	}
+	return &nats.Auth{
+		CACert:     secret.Data[scalingv1.AuthSecretCAKey],
+		ClientCert: secret.Data[scalingv1.AuthSecretCertKey],
+		ClientKey:  secret.Data[scalingv1.AuthSecretKeyKey],
+		Token:      string(secret.Data[scalingv1.AuthSecretTokenKey]),
+		Username:   string(secret.Data[scalingv1.AuthSecretUsernameKey]),
+		Password:   string(secret.Data[scalingv1.AuthSecretPasswordKey]),
+	}, nil
+}
+
+// connectionsForSecret returns the connections reading their credentials from the secret. Secrets change
+// rarely, so the connections are filtered in memory rather than through an index. A NatsConnection only
+// reads secrets of its own namespace.
+func connectionsForSecret(ctx context.Context, c client.Reader, secret client.Object) ([]scalingv1.NatsConnection, []scalingv1.ClusterNatsConnection, error) {
+	refersTo := func(ref *scalingv1.NamespacedSecretReference, defaultNamespace string) bool {
+		if ref == nil || ref.Name != secret.GetName() {
+			return false
+		}
+		ns := ref.Namespace
+		if ns == "" {
+			ns = defaultNamespace
+		}
+		return ns == secret.GetNamespace()
+	}
+
+	var conns scalingv1.NatsConnectionList
+	if err := c.List(ctx, &conns, client.InNamespace(secret.GetNamespace())); err != nil {
+		return nil, nil, fmt.Errorf("failed to list NatsConnections: %w", err)
+	}
+	var namespaced []scalingv1.NatsConnection
+	for _, conn := range conns.Items {
+		if refersTo(conn.Spec.AuthSecretRef, conn.Namespace) {
+			namespaced = append(namespaced, conn)
+		}
+	}
+
+	var clusterConns scalingv1.ClusterNatsConnectionList
+	if err := c.List(ctx, &clusterConns); err != nil {
+		return nil, nil, fmt.Errorf("failed to list ClusterNatsConnections: %w", err)
+	}
+	var cluster []scalingv1.ClusterNatsConnection
+	for _, conn := range clusterConns.Items {
+		if refersTo(conn.Spec.AuthSecretRef, "") {
+			cluster = append(cluster, conn)
+		}
+	}
+	return namespaced, cluster, nil
+}
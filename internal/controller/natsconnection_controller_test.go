@@ -0,0 +1,143 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	scalingv1 "github.com/Av1shay/nats-scaler/api/v1"
+)
+
+var _ = Describe("NatsConnection Controller", func() {
+	Context("When reconciling a resource", func() {
+		const nsName = "default"
+
+		ctx := context.Background()
+
+		It("should report the server version of a reachable server", func() {
+			By("Create the necessary resources")
+			prober := &mockProber{version: "2.11.4"}
+			typeNamespacedName := types.NamespacedName{
+				Name:      fmt.Sprintf("test-connection-%d", GinkgoParallelProcess()),
+				Namespace: nsName,
+			}
+			conn := &scalingv1.NatsConnection{
+				ObjectMeta: metav1.ObjectMeta{Name: typeNamespacedName.Name, Namespace: nsName},
+				Spec: scalingv1.NatsConnectionSpec{
+					MonitoringURL: "http://nats:8222",
+					AuthSecretRef: &scalingv1.NamespacedSecretReference{Name: "nats-connection-auth"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, conn)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, conn)).To(Succeed())
+			})
+
+			controllerReconciler := &NatsConnectionReconciler{
+				Client:           k8sClient,
+				Scheme:           k8sClient.Scheme(),
+				APIReader:        k8sClient,
+				NatsService:      prober,
+				JetStreamService: &mockProber{err: errors.New("jetstream backend must not be used")},
+			}
+			reconcileAndGet := func() *scalingv1.NatsConnection {
+				res, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res.RequeueAfter).To(Equal(defaultProbeInterval))
+				updated := &scalingv1.NatsConnection{}
+				Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+				return updated
+			}
+
+			By("Reconciling before the auth secret exists")
+			cond := meta.FindStatusCondition(reconcileAndGet().Status.Conditions, scalingv1.ConditionReachable)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(reasonAuthSecretUnavailable))
+
+			By("Reconciling once the auth secret exists")
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "nats-connection-auth", Namespace: nsName},
+				Data:       map[string][]byte{scalingv1.AuthSecretTokenKey: []byte("t0ken")},
+			}
+			Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+			})
+			updated := reconcileAndGet()
+			Expect(updated.Status.ServerVersion).To(Equal("2.11.4"))
+			Expect(updated.Status.LastProbeTime).NotTo(BeNil())
+			cond = meta.FindStatusCondition(updated.Status.Conditions, scalingv1.ConditionReachable)
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			Expect(prober.calledWith).To(Equal("http://nats:8222"))
+			Expect(prober.auth.Token).To(Equal("t0ken"))
+
+			By("Keeping the last known version while the server is unreachable")
+			prober.err = errors.New("connection refused")
+			updated = reconcileAndGet()
+			Expect(updated.Status.ServerVersion).To(Equal("2.11.4"))
+			cond = meta.FindStatusCondition(updated.Status.Conditions, scalingv1.ConditionReachable)
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(reasonProbeFailed))
+		})
+
+		It("should require the auth secret namespace on a ClusterNatsConnection", func() {
+			By("Create the necessary resources")
+			prober := &mockProber{version: "2.11.4"}
+			typeNamespacedName := types.NamespacedName{Name: fmt.Sprintf("test-cluster-connection-%d", GinkgoParallelProcess())}
+			conn := &scalingv1.ClusterNatsConnection{
+				ObjectMeta: metav1.ObjectMeta{Name: typeNamespacedName.Name},
+				Spec: scalingv1.NatsConnectionSpec{
+					Backend:       scalingv1.NatsBackendJetStream,
+					URL:           "nats://nats:4222",
+					AuthSecretRef: &scalingv1.NamespacedSecretReference{Name: "nats-auth"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, conn)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, conn)).To(Succeed())
+			})
+
+			controllerReconciler := &ClusterNatsConnectionReconciler{
+				Client:           k8sClient,
+				Scheme:           k8sClient.Scheme(),
+				NatsService:      &mockProber{err: errors.New("monitoring backend must not be used")},
+				JetStreamService: prober,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			updated := &scalingv1.ClusterNatsConnection{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			cond := meta.FindStatusCondition(updated.Status.Conditions, scalingv1.ConditionReachable)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(reasonInvalidSpec))
+			Expect(prober.calledWith).To(BeEmpty())
+		})
+	})
+})
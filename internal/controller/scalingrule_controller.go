@@ -18,41 +18,150 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"sync"
 	"time"
 
 	scalingv1 "github.com/Av1shay/nats-scaler/api/v1"
+	"github.com/Av1shay/nats-scaler/internal/metrics"
 	"github.com/Av1shay/nats-scaler/internal/nats"
+	"github.com/Av1shay/nats-scaler/internal/schedule"
 	internalTypes "github.com/Av1shay/nats-scaler/internal/types"
+	"github.com/Av1shay/nats-scaler/internal/validation"
+	"github.com/Av1shay/nats-scaler/pkg/errs"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 const (
 	// TODO make configurable
 	errRequeueIntervalLong  = time.Minute
 	errRequeueIntervalShort = 10 * time.Second
+
+	// authSecretIndexKey indexes rules by the name of the Secret holding their NATS credentials.
+	authSecretIndexKey = ".spec.natsAuthSecretRef.name"
+	// connectionIndexKey indexes rules by the "<kind>/<name>" of the NatsConnection they reference.
+	connectionIndexKey = ".spec.natsConnectionRef"
+	// targetIndexKey indexes rules by the "<group>/<kind>/<namespace>/<name>" of the workload they scale.
+	targetIndexKey = ".spec.scaleTargetRef"
+
+	// targetWatchSyncTimeout bounds the background wait for the informer of a newly watched target kind. It
+	// doesn't sync when the controller isn't allowed to list and watch the kind.
+	targetWatchSyncTimeout = 10 * time.Second
+	// targetWatchRetryInterval is how long a target kind that couldn't be watched is polled only.
+	targetWatchRetryInterval = 10 * time.Minute
+
+	// restoreFinalizer holds the deletion of a rule until its target is scaled back to the restore replicas.
+	restoreFinalizer = "scaling.my.domain/restore-replicas"
+)
+
+// Condition reasons set by the reconciler.
+const (
+	reasonReconciled            = "Reconciled"
+	reasonInvalidSpec           = "InvalidSpec"
+	reasonNatsRequestFailed     = "NatsRequestFailed"
+	reasonNatsResponded         = "NatsResponded"
+	reasonAccountNotFound       = "AccountNotFound"
+	reasonConsumerNotFound      = "ConsumerNotFound"
+	reasonAuthSecretUnavailable = "AuthSecretUnavailable"
+	reasonConnectionUnavailable = "NatsConnectionUnavailable"
+	reasonTargetNotFound        = "TargetNotFound"
+	reasonTargetFound           = "TargetFound"
+	reasonScaleFailed           = "ScaleFailed"
+	reasonDesiredComputed       = "DesiredReplicasComputed"
+	reasonCooldownActive        = "CooldownActive"
+	reasonStabilized            = "ScaleStabilized"
+	reasonFallback              = "FallbackActive"
+	reasonActivated             = "Activated"
+	reasonIdle                  = "ConsumerIdle"
+	reasonTooFewReplicas        = "TooFewReplicas"
+	reasonTooManyReplicas       = "TooManyReplicas"
+	reasonDesiredWithinRange    = "DesiredWithinRange"
+	reasonHPAConflict           = "HorizontalPodAutoscalerConflict"
+	reasonRuleConflict          = "ScalingRuleConflict"
+	reasonNoConflict            = "NoConflict"
+	reasonSuspended             = "Suspended"
+	reasonNotSuspended          = "NotSuspended"
+)
+
+// Event reasons recorded on the ScalingRule and, for scaling actions, on the scaled workload.
+const (
+	eventScaledUp              = "ScaledUp"
+	eventScaledDown            = "ScaledDown"
+	eventCooldownActive        = "CooldownActive"
+	eventNatsUnreachable       = "NatsUnreachable"
+	eventConsumerNotFound      = "ConsumerNotFound"
+	eventAccountNotFound       = "AccountNotFound"
+	eventAuthSecretUnavailable = "AuthSecretUnavailable"
+	eventConnectionUnavailable = "NatsConnectionUnavailable"
+	eventTargetNotFound        = "TargetNotFound"
+	eventInvalidSpec           = "InvalidSpec"
+	eventConflict              = "Conflict"
+	eventReplicasRestored      = "ReplicasRestored"
 )
 
 type Scaler interface {
-	ReconcileScale(ctx context.Context, k8s client.Client, nn types.NamespacedName, spec internalTypes.ScalerParams, pendings int) error
+	ReconcileScale(ctx context.Context, k8s client.Client, target internalTypes.ScaleTarget, spec internalTypes.ScalerParams) (internalTypes.ScaleResult, error)
+	SetScale(ctx context.Context, k8s client.Client, target internalTypes.ScaleTarget, replicas int32, why string) (internalTypes.ScaleResult, error)
 }
 
 // ScalingRuleReconciler reconciles a ScalingRule object
 type ScalingRuleReconciler struct {
 	client.Client
-	Scheme      *runtime.Scheme
-	NatsService *nats.Service
-	Scaler      Scaler
+	Scheme *runtime.Scheme
+	// APIReader reads the Secrets holding NATS credentials from the API server, only the metadata of
+	// Secrets is cached. Client is used when it is nil.
+	APIReader   client.Reader
+	NatsService nats.Backend
+	// JetStreamService serves rules with natsBackend set to jetstream.
+	JetStreamService nats.Backend
+	Scaler           Scaler
+	Recorder         record.EventRecorder
+
+	// controller and cache start watches on the kinds of scale targets as rules reference them, they are
+	// set by SetupWithManager.
+	controller     controller.Controller
+	cache          cache.Cache
+	watchMu        sync.Mutex
+	watchedTargets map[schema.GroupKind]struct{}
+	// unwatchedTargets holds the time the watch of a kind that failed to sync is retried
+	unwatchedTargets map[schema.GroupKind]time.Time
 }
 
 // +kubebuilder:rbac:groups=scaling.my.domain,resources=scalingrules,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=scaling.my.domain,resources=scalingrules/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=scaling.my.domain,resources=scalingrules/finalizers,verbs=update
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=argoproj.io,resources=rollouts,verbs=get;list;watch
+// +kubebuilder:rbac:groups=*,resources=*/scale,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -65,50 +174,985 @@ func (r *ScalingRuleReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	if err := r.Get(ctx, req.NamespacedName, &rule); err != nil {
 		if client.IgnoreNotFound(err) == nil {
 			// if the resource deleted, we don't need to reconcile it again
+			metrics.DeleteRule(req.Namespace, req.Name)
 			return ctrl.Result{}, nil
 		}
 		logger.Error(err, "failed to get ScalingRule", "retryIn", errRequeueIntervalLong)
 		return ctrl.Result{RequeueAfter: errRequeueIntervalLong}, nil
 	}
-	if err := validateScalingRuleSpec(rule.Spec); err != nil {
+	if !rule.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, &rule)
+	}
+	if patch := client.MergeFrom(rule.DeepCopy()); controllerutil.AddFinalizer(&rule, restoreFinalizer) {
+		if err := r.Patch(ctx, &rule, patch); err != nil {
+			logger.Error(err, "failed to add finalizer", "retryIn", errRequeueIntervalShort)
+			return ctrl.Result{RequeueAfter: errRequeueIntervalShort}, nil
+		}
+	}
+	base := rule.DeepCopy()
+	// the admission webhook rejects invalid specs up front, this is a safety net for clusters running with
+	// ENABLE_WEBHOOKS=false
+	if err := validation.ValidateScalingRuleSpec(rule.Spec, field.NewPath("spec")).ToAggregate(); err != nil {
 		logger.Error(err, "invalid ScalingRule spec", "retryIn", errRequeueIntervalLong)
+		r.Recorder.Event(&rule, corev1.EventTypeWarning, eventInvalidSpec, err.Error())
+		setCondition(&rule, scalingv1.ConditionScalingActive, metav1.ConditionFalse, reasonInvalidSpec, err.Error())
+		setCondition(&rule, scalingv1.ConditionReady, metav1.ConditionFalse, reasonInvalidSpec, err.Error())
+		r.updateStatus(ctx, base, &rule)
 		return ctrl.Result{RequeueAfter: errRequeueIntervalLong}, nil
 	}
+	// the overrides of the open schedule window apply to this reconcile only, the spec is never written back
+	now := time.Now()
+	rule.Status.ActiveSchedule = ""
+	if w := activeSchedule(rule.Spec.Schedules, now); w != nil {
+		rule.Spec.ApplySchedule(w)
+		rule.Status.ActiveSchedule = w.Name
+	}
+	pollInterval := time.Duration(rule.Spec.PollIntervalSeconds) * time.Second
+	if next, ok := nextScheduleStart(rule.Spec.Schedules, now); ok && next.Sub(now) < pollInterval {
+		// open the window on time, consumers are pre-warmed before the peak rather than during it
+		pollInterval = next.Sub(now)
+	}
 
-	pendings, err := r.NatsService.GetPendingMessages(ctx, rule.Spec.NatsMonitoringURL, rule.Spec.StreamName, rule.Spec.ConsumerName)
+	target, err := scaleTarget(rule.Spec)
 	if err != nil {
-		logger.Error(err, "failed to get pending messages from NATS", "retryIn", errRequeueIntervalShort)
+		logger.Error(err, "invalid ScalingRule scale target", "retryIn", errRequeueIntervalLong)
+		r.Recorder.Event(&rule, corev1.EventTypeWarning, eventInvalidSpec, err.Error())
+		setCondition(&rule, scalingv1.ConditionScalingActive, metav1.ConditionFalse, reasonInvalidSpec, err.Error())
+		setCondition(&rule, scalingv1.ConditionReady, metav1.ConditionFalse, reasonInvalidSpec, err.Error())
+		r.updateStatus(ctx, base, &rule)
+		return ctrl.Result{RequeueAfter: errRequeueIntervalLong}, nil
+	}
+	ref := rule.Spec.ScaleTarget()
+	rule.Status.ScaleTarget = &ref
+	if err := r.watchTarget(ctx, target.GroupVersionKind); err != nil {
+		// not fatal, changes to the target are picked up on the next poll instead
+		logger.Error(err, "failed to watch scale target", "kind", target.GroupVersionKind)
+	}
+
+	reason, conflict, err := r.conflict(ctx, &rule, target)
+	if err != nil {
+		logger.Error(err, "failed to check the scale target for conflicts", "retryIn", errRequeueIntervalShort)
 		return ctrl.Result{RequeueAfter: errRequeueIntervalShort}, nil
 	}
+	if conflict != "" {
+		logger.Info("scale target conflict, not scaling", "conflict", conflict, "retryIn", errRequeueIntervalLong)
+		r.Recorder.Event(&rule, corev1.EventTypeWarning, eventConflict, conflict)
+		setCondition(&rule, scalingv1.ConditionConflict, metav1.ConditionTrue, reason, conflict)
+		setCondition(&rule, scalingv1.ConditionScalingActive, metav1.ConditionFalse, reason, conflict)
+		setCondition(&rule, scalingv1.ConditionReady, metav1.ConditionFalse, reason, conflict)
+		r.updateStatus(ctx, base, &rule)
+		return ctrl.Result{RequeueAfter: errRequeueIntervalLong}, nil
+	}
+	setCondition(&rule, scalingv1.ConditionConflict, metav1.ConditionFalse, reasonNoConflict,
+		"no HorizontalPodAutoscaler or older ScalingRule scales the target")
 
-	if err := r.Scaler.ReconcileScale(ctx, r.Client, types.NamespacedName{
-		Name:      rule.Spec.DeploymentName,
-		Namespace: rule.Spec.Namespace,
-	}, internalTypes.ScalerParams{
-		MinReplicas:        rule.Spec.MinReplicas,
-		MaxReplicas:        rule.Spec.MaxReplicas,
-		ScaleUpThreshold:   rule.Spec.ScaleUpThreshold,
-		ScaleDownThreshold: rule.Spec.ScaleDownThreshold,
-	}, pendings); err != nil {
-		logger.Error(err, "failed to get pending messages from NATS", "retryIn", errRequeueIntervalShort)
+	conn, connNamespace, err := r.natsConnection(ctx, &rule)
+	if err != nil {
+		logger.Error(err, "failed to resolve NATS connection", "retryIn", errRequeueIntervalShort)
+		r.Recorder.Event(&rule, corev1.EventTypeWarning, eventConnectionUnavailable, err.Error())
+		r.natsUnavailable(ctx, base, &rule, target, reasonConnectionUnavailable, err)
+		return ctrl.Result{RequeueAfter: errRequeueIntervalShort}, nil
+	}
+	auth, err := readNatsAuth(ctx, secretReader(r.APIReader, r.Client), conn.AuthSecretRef, connNamespace)
+	if err != nil {
+		logger.Error(err, "failed to read NATS credentials", "retryIn", errRequeueIntervalShort)
+		r.Recorder.Event(&rule, corev1.EventTypeWarning, eventAuthSecretUnavailable, err.Error())
+		r.natsUnavailable(ctx, base, &rule, target, reasonAuthSecretUnavailable, err)
 		return ctrl.Result{RequeueAfter: errRequeueIntervalShort}, nil
 	}
 
-	return ctrl.Result{RequeueAfter: time.Duration(rule.Spec.PollIntervalSeconds) * time.Second}, nil
+	backend, query := r.natsBackend(conn)
+	query.Auth = auth
+	query.Rates = rule.Spec.ScalingPolicy == scalingv1.ScalingPolicyThroughput
+	triggers := rule.Spec.EffectiveTriggers()
+	scalerTriggers := make([]internalTypes.Trigger, 0, len(triggers))
+	triggerPending := make(map[string]int, len(triggers))
+	triggerRates := make(map[string]nats.Rates, len(triggers))
+	totalPending, idle := 0, true
+	for _, t := range triggers {
+		account := t.Account
+		if account == "" {
+			account = conn.Account
+		}
+		query.Account, query.Stream, query.Consumer = account, t.StreamName, t.ConsumerName
+		state, err := backend.GetConsumerState(ctx, query)
+		if err != nil {
+			logger.Error(err, "failed to get pending messages from NATS", "trigger", t.Name, "retryIn", errRequeueIntervalShort)
+			var accErr *errs.AccountNotFoundErr
+			var consumerErr *errs.ConsumerNotFoundErr
+			switch {
+			case errors.As(err, &accErr):
+				r.Recorder.Event(&rule, corev1.EventTypeWarning, eventAccountNotFound, err.Error())
+				r.consumerNotFound(ctx, base, &rule, target, reasonAccountNotFound, err)
+			case errors.As(err, &consumerErr):
+				r.Recorder.Event(&rule, corev1.EventTypeWarning, eventConsumerNotFound, err.Error())
+				r.consumerNotFound(ctx, base, &rule, target, reasonConsumerNotFound, err)
+			default:
+				r.Recorder.Event(&rule, corev1.EventTypeWarning, eventNatsUnreachable, err.Error())
+				r.natsUnavailable(ctx, base, &rule, target, reasonNatsRequestFailed, err)
+			}
+			return ctrl.Result{RequeueAfter: errRequeueIntervalShort}, nil
+		}
+		triggerPending[t.Name] = state.NumPending
+		triggerRates[t.Name] = state.Rates
+		totalPending += state.NumPending
+		idle = idle && state.NumPending == 0 && state.NumAckPending == 0
+		st := internalTypes.Trigger{
+			Name:                    t.Name,
+			Value:                   metricValue(t, state),
+			Pending:                 state.NumPending,
+			ScaleUpThreshold:        t.ScaleUpThreshold,
+			ScaleDownThreshold:      t.ScaleDownThreshold,
+			TargetPendingPerReplica: t.TargetPendingPerReplica,
+			IngressRate:             state.Rates.Ingress,
+			RateKnown:               state.Rates.Known,
+		}
+		if t.TargetRatePerReplica != nil {
+			st.TargetRatePerReplica = t.TargetRatePerReplica.AsApproximateFloat64()
+		}
+		scalerTriggers = append(scalerTriggers, st)
+	}
+	rule.Status.LastObservedPending = totalPending
+	rule.Status.ConsecutiveFailures, rule.Status.LastFailureTime = 0, nil
+	metrics.RecordPending(rule.Namespace, rule.Name, totalPending)
+	setCondition(&rule, scalingv1.ConditionNatsReachable, metav1.ConditionTrue, reasonNatsResponded,
+		fmt.Sprintf("%d consumer(s) reported %d pending messages", len(triggers), totalPending))
+	switch {
+	case !idle:
+		rule.Status.IdleSince = nil
+	case rule.Status.IdleSince == nil:
+		rule.Status.IdleSince = &metav1.Time{Time: time.Now()}
+	}
+
+	if rule.Spec.Suspend {
+		if r.suspend(ctx, &rule, target) {
+			setCondition(&rule, scalingv1.ConditionReady, metav1.ConditionTrue, reasonReconciled, "rule evaluated successfully")
+		}
+		r.updateStatus(ctx, base, &rule)
+		return ctrl.Result{RequeueAfter: pollInterval}, nil
+	}
+	setCondition(&rule, scalingv1.ConditionSuspended, metav1.ConditionFalse, reasonNotSuspended, "the rule scales its target")
+
+	params := internalTypes.ScalerParams{
+		Policy:      internalTypes.ScalingPolicy(rule.Spec.ScalingPolicy),
+		MinReplicas: rule.Spec.MinReplicas,
+		MaxReplicas: rule.Spec.MaxReplicas,
+		Triggers:    scalerTriggers,
+	}
+	if rule.Status.LastScaleTime != nil {
+		params.LastScaleTime = rule.Status.LastScaleTime.Time
+	}
+	if stz := rule.Spec.ScaleToZero; stz != nil {
+		params.ScaleToZero = scaleToZeroParams(stz, rule.Status.IdleSince)
+	}
+	if b := rule.Spec.Behavior; b != nil {
+		params.ScaleUpStabilizationWindow = stabilizationWindow(b.ScaleUp)
+		params.ScaleDownStabilizationWindow = stabilizationWindow(b.ScaleDown)
+	}
+	if p := rule.Spec.Prediction; p != nil {
+		params.Prediction = predictionParams(p, rule.Spec.PollIntervalSeconds)
+	}
+	res, err := r.Scaler.ReconcileScale(ctx, r.Client, target, params)
+	if err != nil {
+		logger.Error(err, "failed to reconcile scale", "retryIn", errRequeueIntervalShort)
+		if apierrors.IsNotFound(err) {
+			r.Recorder.Event(&rule, corev1.EventTypeWarning, eventTargetNotFound, err.Error())
+			setCondition(&rule, scalingv1.ConditionTargetFound, metav1.ConditionFalse, reasonTargetNotFound, err.Error())
+		}
+		setCondition(&rule, scalingv1.ConditionScalingActive, metav1.ConditionFalse, reasonScaleFailed, err.Error())
+		setCondition(&rule, scalingv1.ConditionReady, metav1.ConditionFalse, reasonScaleFailed, err.Error())
+		r.updateStatus(ctx, base, &rule)
+		return ctrl.Result{RequeueAfter: errRequeueIntervalShort}, nil
+	}
+
+	rule.Status.CurrentReplicas = res.CurrentReplicas
+	rule.Status.DesiredReplicas = res.DesiredReplicas
+	recordBaseline(&rule, res)
+	rule.Status.Triggers = make([]scalingv1.TriggerStatus, 0, len(res.Triggers))
+	for _, t := range res.Triggers {
+		ts := scalingv1.TriggerStatus{
+			Name:            t.Name,
+			Pending:         triggerPending[t.Name],
+			Value:           t.Value,
+			Predicted:       t.Predicted,
+			DesiredReplicas: t.DesiredReplicas,
+		}
+		if rates := triggerRates[t.Name]; rates.Known {
+			ts.IngressRate, ts.AckRate = rateQuantity(rates.Ingress), rateQuantity(rates.Ack)
+		}
+		rule.Status.Triggers = append(rule.Status.Triggers, ts)
+	}
+	// the scaler reports no per trigger results when it activates the target from zero, take the value
+	// from the triggers it was given
+	rule.Status.LastObservedValue = scalerTriggers[0].Value
+	for _, t := range scalerTriggers {
+		if t.Name == res.ActiveTrigger {
+			rule.Status.LastObservedValue = t.Value
+		}
+	}
+	recordScaleMetrics(&rule, res)
+	r.recordScaleEvents(&rule, target, res, rule.Status.LastObservedValue)
+	if !res.LastScaleTime.IsZero() {
+		rule.Status.LastScaleTime = &metav1.Time{Time: res.LastScaleTime}
+	}
+	setCondition(&rule, scalingv1.ConditionTargetFound, metav1.ConditionTrue, reasonTargetFound,
+		fmt.Sprintf("%s %s found", target.GroupVersionKind.Kind, target.NamespacedName))
+	switch {
+	case res.Activated:
+		setCondition(&rule, scalingv1.ConditionScalingActive, metav1.ConditionTrue, reasonActivated,
+			fmt.Sprintf("pending messages woke the target up to %d replicas", res.DesiredReplicas))
+	case res.Idle:
+		setCondition(&rule, scalingv1.ConditionScalingActive, metav1.ConditionTrue, reasonIdle,
+			"the consumer is idle, the target is scaled to zero")
+	case res.CooldownActive:
+		setCondition(&rule, scalingv1.ConditionScalingActive, metav1.ConditionTrue, reasonCooldownActive,
+			fmt.Sprintf("scaling to %d replicas is delayed by cooldown", res.DesiredReplicas))
+	case res.Stabilized:
+		setCondition(&rule, scalingv1.ConditionScalingActive, metav1.ConditionTrue, reasonStabilized,
+			fmt.Sprintf("recent recommendations within the stabilization window hold the replicas at %d", res.DesiredReplicas))
+	default:
+		setCondition(&rule, scalingv1.ConditionScalingActive, metav1.ConditionTrue, reasonDesiredComputed,
+			fmt.Sprintf("desired replicas computed as %d", res.DesiredReplicas))
+	}
+	switch {
+	case res.Limited && res.DesiredReplicas >= rule.Spec.MaxReplicas:
+		setCondition(&rule, scalingv1.ConditionScalingLimited, metav1.ConditionTrue, reasonTooManyReplicas,
+			fmt.Sprintf("desired replicas are capped by maxReplicas (%d)", rule.Spec.MaxReplicas))
+	case res.Limited:
+		setCondition(&rule, scalingv1.ConditionScalingLimited, metav1.ConditionTrue, reasonTooFewReplicas,
+			fmt.Sprintf("desired replicas are floored by minReplicas (%d)", rule.Spec.MinReplicas))
+	default:
+		setCondition(&rule, scalingv1.ConditionScalingLimited, metav1.ConditionFalse, reasonDesiredWithinRange,
+			"desired replicas are within the allowed range")
+	}
+	setCondition(&rule, scalingv1.ConditionReady, metav1.ConditionTrue, reasonReconciled, "rule evaluated successfully")
+	r.updateStatus(ctx, base, &rule)
+
+	return ctrl.Result{RequeueAfter: pollInterval}, nil
+}
+
+func parseScheduleWindow(w scalingv1.ScheduleWindow) (*schedule.Window, error) {
+	return schedule.Parse(w.Start, w.TimeZone, time.Duration(w.DurationSeconds)*time.Second)
 }
 
-// ScalingRule runtime validation for spec, in real-world we will do this in a webhook
-func validateScalingRuleSpec(spec scalingv1.ScalingRuleSpec) error {
-	if spec.MinReplicas > spec.MaxReplicas {
-		return fmt.Errorf("minReplicas (%d) must be less than or equal to maxReplicas (%d)", spec.MinReplicas, spec.MaxReplicas)
+// activeSchedule returns the first window open at now, nil when none is. Windows that don't parse are
+// skipped, the spec validation reports them.
+func activeSchedule(windows []scalingv1.ScheduleWindow, now time.Time) *scalingv1.ScheduleWindow {
+	for i := range windows {
+		if w, err := parseScheduleWindow(windows[i]); err == nil && w.Active(now) {
+			return &windows[i]
+		}
 	}
 	return nil
 }
 
+// nextScheduleStart returns the next time one of the windows opens.
+func nextScheduleStart(windows []scalingv1.ScheduleWindow, now time.Time) (time.Time, bool) {
+	var next time.Time
+	for _, sw := range windows {
+		w, err := parseScheduleWindow(sw)
+		if err != nil {
+			continue
+		}
+		if start := w.NextStart(now); !start.IsZero() && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// metricValue returns the value of the consumer metric selected by the trigger.
+func metricValue(t scalingv1.ScalingTrigger, state nats.ConsumerState) int {
+	var w nats.MetricWeights
+	switch t.Metric {
+	case scalingv1.ScalingMetricAckPending:
+		w.AckPending = 1
+	case scalingv1.ScalingMetricRedelivered:
+		w.Redelivered = 1
+	case scalingv1.ScalingMetricWaiting:
+		w.Waiting = 1
+	case scalingv1.ScalingMetricWeighted:
+		if mw := t.MetricWeights; mw != nil {
+			w = nats.MetricWeights{
+				Pending:     int(mw.Pending),
+				AckPending:  int(mw.AckPending),
+				Redelivered: int(mw.Redelivered),
+				Waiting:     int(mw.Waiting),
+			}
+		}
+	default:
+		w.Pending = 1
+	}
+	return state.Weighted(w)
+}
+
+// natsConnection returns the NATS connection of the rule, described inline or by the NatsConnection it
+// references, together with the namespace its auth secret defaults to.
+func (r *ScalingRuleReconciler) natsConnection(ctx context.Context, rule *scalingv1.ScalingRule) (scalingv1.NatsConnectionSpec, string, error) {
+	ref := rule.Spec.NatsConnectionRef
+	if ref == nil {
+		conn := scalingv1.NatsConnectionSpec{
+			Backend:        rule.Spec.NatsBackend,
+			MonitoringURL:  rule.Spec.NatsMonitoringURL,
+			MonitoringURLs: rule.Spec.NatsMonitoringURLs,
+			Failover:       rule.Spec.NatsFailover,
+			URL:            rule.Spec.NatsURL,
+		}
+		if secretRef := rule.Spec.NatsAuthSecretRef; secretRef != nil {
+			conn.AuthSecretRef = &scalingv1.NamespacedSecretReference{Name: secretRef.Name}
+		}
+		return conn, rule.Namespace, nil
+	}
+
+	var (
+		spec      scalingv1.NatsConnectionSpec
+		namespace string
+	)
+	switch ref.Kind {
+	case scalingv1.ClusterNatsConnectionKind:
+		var conn scalingv1.ClusterNatsConnection
+		if err := r.Get(ctx, types.NamespacedName{Name: ref.Name}, &conn); err != nil {
+			return scalingv1.NatsConnectionSpec{}, "", fmt.Errorf("failed to get ClusterNatsConnection %s: %w", ref.Name, err)
+		}
+		spec = conn.Spec
+	default:
+		var conn scalingv1.NatsConnection
+		if err := r.Get(ctx, types.NamespacedName{Namespace: rule.Namespace, Name: ref.Name}, &conn); err != nil {
+			return scalingv1.NatsConnectionSpec{}, "", fmt.Errorf("failed to get NatsConnection %s: %w", ref.Name, err)
+		}
+		spec, namespace = conn.Spec, conn.Namespace
+	}
+	if err := validateNatsConnectionSpec(spec, namespace); err != nil {
+		return scalingv1.NatsConnectionSpec{}, "", fmt.Errorf("invalid %s %s: %w", connectionKind(ref), ref.Name, err)
+	}
+	return spec, namespace, nil
+}
+
+func connectionKind(ref *scalingv1.NatsConnectionReference) string {
+	if ref.Kind == "" {
+		return scalingv1.NatsConnectionKind
+	}
+	return ref.Kind
+}
+
+// natsBackend returns the backend selected by the connection together with the endpoints it should query.
+func (r *ScalingRuleReconciler) natsBackend(conn scalingv1.NatsConnectionSpec) (nats.Backend, nats.ConsumerQuery) {
+	if conn.Backend == scalingv1.NatsBackendJetStream {
+		return r.JetStreamService, nats.ConsumerQuery{URL: conn.URL}
+	}
+	q := nats.ConsumerQuery{RoundRobin: conn.Failover == scalingv1.FailoverPolicyRoundRobin}
+	if urls := conn.MonitoringEndpoints(); len(urls) > 0 {
+		q.URL, q.FallbackURLs = urls[0], urls[1:]
+	}
+	return r.NatsService, q
+}
+
+// natsUnavailable reports that the consumers couldn't be looked up and counts the failure, at most once
+// per poll interval so that reconciles triggered by watches don't reach the threshold early. Once the
+// rule's fallback threshold is reached the target is scaled to the fallback replicas, otherwise the
+// replicas are held since no scaling decision can be made.
+func (r *ScalingRuleReconciler) natsUnavailable(ctx context.Context, base, rule *scalingv1.ScalingRule,
+	target internalTypes.ScaleTarget, reason string, err error) {
+	logger := logf.FromContext(ctx)
+	now := time.Now()
+	pollInterval := time.Duration(rule.Spec.PollIntervalSeconds) * time.Second
+	if last := rule.Status.LastFailureTime; last == nil || now.Sub(last.Time) >= pollInterval {
+		rule.Status.ConsecutiveFailures++
+		rule.Status.LastFailureTime = &metav1.Time{Time: now}
+	}
+	setCondition(rule, scalingv1.ConditionNatsReachable, metav1.ConditionFalse, reason, err.Error())
+	setCondition(rule, scalingv1.ConditionReady, metav1.ConditionFalse, reason, err.Error())
+	defer r.updateStatus(ctx, base, rule)
+
+	if rule.Spec.Suspend {
+		// a suspended rule doesn't fall back, the pinned replicas don't depend on the metrics
+		r.suspend(ctx, rule, target)
+		return
+	}
+	replicas, ok := fallbackReplicas(rule)
+	if !ok {
+		setCondition(rule, scalingv1.ConditionScalingActive, metav1.ConditionFalse, reason, "pending messages are unknown")
+		return
+	}
+	params := internalTypes.ScalerParams{
+		MinReplicas:      rule.Spec.MinReplicas,
+		MaxReplicas:      rule.Spec.MaxReplicas,
+		FallbackReplicas: &replicas,
+	}
+	if rule.Status.LastScaleTime != nil {
+		params.LastScaleTime = rule.Status.LastScaleTime.Time
+	}
+	res, err := r.Scaler.ReconcileScale(ctx, r.Client, target, params)
+	if err != nil {
+		logger.Error(err, "failed to scale to the fallback replicas")
+		setCondition(rule, scalingv1.ConditionScalingActive, metav1.ConditionFalse, reasonScaleFailed, err.Error())
+		return
+	}
+
+	rule.Status.CurrentReplicas = res.CurrentReplicas
+	rule.Status.DesiredReplicas = res.DesiredReplicas
+	recordBaseline(rule, res)
+	if !res.LastScaleTime.IsZero() {
+		rule.Status.LastScaleTime = &metav1.Time{Time: res.LastScaleTime}
+	}
+	recordScaleMetrics(rule, res)
+	r.recordScaleEvents(rule, target, res, 0)
+	setCondition(rule, scalingv1.ConditionScalingActive, metav1.ConditionTrue, reasonFallback,
+		fmt.Sprintf("metrics unavailable for %d consecutive reconciles, scaling to the fallback of %d replicas",
+			rule.Status.ConsecutiveFailures, res.DesiredReplicas))
+}
+
+// consumerNotFound reports that NATS answered but doesn't know the account or consumer of a trigger. The
+// replicas are held, the fallback only covers NATS being unreachable and a misconfigured trigger must not
+// scale the target.
+func (r *ScalingRuleReconciler) consumerNotFound(ctx context.Context, base, rule *scalingv1.ScalingRule,
+	target internalTypes.ScaleTarget, reason string, err error) {
+	rule.Status.ConsecutiveFailures, rule.Status.LastFailureTime = 0, nil
+	setCondition(rule, scalingv1.ConditionNatsReachable, metav1.ConditionFalse, reason, err.Error())
+	setCondition(rule, scalingv1.ConditionReady, metav1.ConditionFalse, reason, err.Error())
+	defer r.updateStatus(ctx, base, rule)
+
+	if rule.Spec.Suspend {
+		r.suspend(ctx, rule, target)
+		return
+	}
+	setCondition(rule, scalingv1.ConditionScalingActive, metav1.ConditionFalse, reason,
+		"the replicas are held until the consumer is found")
+}
+
+// suspend reports the rule as suspended and scales the target to the pinned replicas, if any, instead of
+// following the triggers. It returns false when the target couldn't be scaled.
+func (r *ScalingRuleReconciler) suspend(ctx context.Context, rule *scalingv1.ScalingRule, target internalTypes.ScaleTarget) bool {
+	setCondition(rule, scalingv1.ConditionSuspended, metav1.ConditionTrue, reasonSuspended, "scaling is suspended by spec.suspend")
+	pinned := rule.Spec.SuspendedReplicas
+	if pinned == nil {
+		setCondition(rule, scalingv1.ConditionScalingActive, metav1.ConditionFalse, reasonSuspended,
+			"scaling is suspended, the replicas are left as they are")
+		return true
+	}
+
+	res, err := r.Scaler.SetScale(ctx, r.Client, target, *pinned, "suspended")
+	if err != nil {
+		logf.FromContext(ctx).Error(err, "failed to scale to the suspended replicas")
+		if apierrors.IsNotFound(err) {
+			r.Recorder.Event(rule, corev1.EventTypeWarning, eventTargetNotFound, err.Error())
+			setCondition(rule, scalingv1.ConditionTargetFound, metav1.ConditionFalse, reasonTargetNotFound, err.Error())
+		}
+		setCondition(rule, scalingv1.ConditionScalingActive, metav1.ConditionFalse, reasonScaleFailed, err.Error())
+		setCondition(rule, scalingv1.ConditionReady, metav1.ConditionFalse, reasonScaleFailed, err.Error())
+		return false
+	}
+	rule.Status.CurrentReplicas = res.CurrentReplicas
+	rule.Status.DesiredReplicas = res.DesiredReplicas
+	recordBaseline(rule, res)
+	if !res.LastScaleTime.IsZero() {
+		rule.Status.LastScaleTime = &metav1.Time{Time: res.LastScaleTime}
+	}
+	recordScaleMetrics(rule, res)
+	if res.Scaled {
+		reason := eventScaledUp
+		if res.DesiredReplicas < res.CurrentReplicas {
+			reason = eventScaledDown
+		}
+		r.recordTargetEvent(rule, target, res.TargetUID, reason, fmt.Sprintf("Scaled %s %s from %d to %d replicas (suspended)",
+			target.GroupVersionKind.Kind, target.Name, res.CurrentReplicas, res.DesiredReplicas))
+	}
+	setCondition(rule, scalingv1.ConditionTargetFound, metav1.ConditionTrue, reasonTargetFound,
+		fmt.Sprintf("%s %s found", target.GroupVersionKind.Kind, target.NamespacedName))
+	setCondition(rule, scalingv1.ConditionScalingActive, metav1.ConditionFalse, reasonSuspended,
+		fmt.Sprintf("scaling is suspended, the replicas are pinned to %d", res.DesiredReplicas))
+	return true
+}
+
+// recordBaseline remembers the replicas the target had before the rule first scaled it.
+func recordBaseline(rule *scalingv1.ScalingRule, res internalTypes.ScaleResult) {
+	if rule.Status.BaselineReplicas == nil && res.Scaled {
+		rule.Status.BaselineReplicas = &res.CurrentReplicas
+	}
+}
+
+// finalize scales the target of a deleted rule back to its restore replicas and releases the rule. The
+// target is left alone when the rule never scaled it, or when another autoscaler now scales it.
+func (r *ScalingRuleReconciler) finalize(ctx context.Context, rule *scalingv1.ScalingRule) (ctrl.Result, error) {
+	logger := logf.FromContext(ctx)
+	if !controllerutil.ContainsFinalizer(rule, restoreFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	replicas := rule.Status.BaselineReplicas
+	if rule.Spec.RestoreReplicas != nil {
+		replicas = rule.Spec.RestoreReplicas
+	}
+	target, err := scaleTarget(rule.Spec)
+	if err != nil {
+		logger.Error(err, "invalid ScalingRule scale target, not restoring its replicas")
+		replicas = nil
+	}
+	if replicas != nil {
+		_, conflict, err := r.conflict(ctx, rule, target)
+		if err != nil {
+			logger.Error(err, "failed to check the scale target for conflicts", "retryIn", errRequeueIntervalShort)
+			return ctrl.Result{RequeueAfter: errRequeueIntervalShort}, nil
+		}
+		if conflict != "" {
+			logger.Info("scale target conflict, not restoring its replicas", "conflict", conflict)
+			replicas = nil
+		}
+	}
+	if replicas != nil {
+		res, err := r.Scaler.SetScale(ctx, r.Client, target, *replicas, "restoring the replicas of a deleted rule")
+		switch {
+		case apierrors.IsNotFound(err):
+			logger.Info("scale target not found, nothing to restore", "target", target.NamespacedName)
+		case err != nil:
+			logger.Error(err, "failed to restore the replicas of the scale target", "retryIn", errRequeueIntervalShort)
+			return ctrl.Result{RequeueAfter: errRequeueIntervalShort}, nil
+		case res.Scaled:
+			r.recordTargetEvent(rule, target, res.TargetUID, eventReplicasRestored, fmt.Sprintf(
+				"Restored %s %s from %d to %d replicas on deletion",
+				target.GroupVersionKind.Kind, target.Name, res.CurrentReplicas, res.DesiredReplicas))
+		}
+	}
+
+	patch := client.MergeFrom(rule.DeepCopy())
+	controllerutil.RemoveFinalizer(rule, restoreFinalizer)
+	if err := r.Patch(ctx, rule, patch); err != nil {
+		logger.Error(err, "failed to remove finalizer", "retryIn", errRequeueIntervalShort)
+		return ctrl.Result{RequeueAfter: errRequeueIntervalShort}, nil
+	}
+	metrics.DeleteRule(rule.Namespace, rule.Name)
+	return ctrl.Result{}, nil
+}
+
+// fallbackReplicas returns the replicas the rule falls back to, false while the replicas are held.
+func fallbackReplicas(rule *scalingv1.ScalingRule) (int32, bool) {
+	fb := rule.Spec.Fallback
+	if fb == nil || rule.Status.ConsecutiveFailures < max(fb.FailureThreshold, 1) {
+		return 0, false
+	}
+	switch fb.Action {
+	case scalingv1.FallbackActionMax:
+		return rule.Spec.MaxReplicas, true
+	case scalingv1.FallbackActionReplicas:
+		if fb.Replicas != nil {
+			return *fb.Replicas, true
+		}
+	}
+	return 0, false
+}
+
+// recordScaleEvents records the scaling action on both the rule and the workload, so that it shows up
+// when describing either of them.
+func (r *ScalingRuleReconciler) recordScaleEvents(rule *scalingv1.ScalingRule, target internalTypes.ScaleTarget, res internalTypes.ScaleResult, pending int) {
+	var reason string
+	msg := fmt.Sprintf("Scaled %s %s from %d to %d replicas (pending: %d)",
+		target.GroupVersionKind.Kind, target.Name, res.CurrentReplicas, res.DesiredReplicas, pending)
+	if res.Fallback {
+		msg = fmt.Sprintf("Scaled %s %s from %d to %d replicas (fallback, NATS metrics unavailable)",
+			target.GroupVersionKind.Kind, target.Name, res.CurrentReplicas, res.DesiredReplicas)
+	}
+	switch {
+	case res.Scaled && res.DesiredReplicas > res.CurrentReplicas:
+		reason = eventScaledUp
+	case res.Scaled:
+		reason = eventScaledDown
+	case res.CooldownActive:
+		reason = eventCooldownActive
+		msg = fmt.Sprintf("Scaling %s %s from %d to %d replicas is delayed by cooldown (pending: %d)",
+			target.GroupVersionKind.Kind, target.Name, res.CurrentReplicas, res.DesiredReplicas, pending)
+	default:
+		return
+	}
+
+	r.recordTargetEvent(rule, target, res.TargetUID, reason, msg)
+}
+
+// recordTargetEvent records a normal event on the rule and, when its UID is known, on the target.
+func (r *ScalingRuleReconciler) recordTargetEvent(rule *scalingv1.ScalingRule, target internalTypes.ScaleTarget, uid types.UID, reason, msg string) {
+	r.Recorder.Event(rule, corev1.EventTypeNormal, reason, msg)
+	if uid == "" {
+		return
+	}
+	apiVersion, kind := target.GroupVersionKind.ToAPIVersionAndKind()
+	r.Recorder.Event(&corev1.ObjectReference{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Name:       target.Name,
+		Namespace:  target.Namespace,
+		UID:        uid,
+	}, corev1.EventTypeNormal, reason, fmt.Sprintf("%s (ScalingRule %s)", msg, rule.Name))
+}
+
+func recordScaleMetrics(rule *scalingv1.ScalingRule, res internalTypes.ScaleResult) {
+	metrics.RecordReplicas(rule.Namespace, rule.Name, res.CurrentReplicas, res.DesiredReplicas)
+	switch {
+	case res.Scaled && res.DesiredReplicas > res.CurrentReplicas:
+		metrics.RecordScale(rule.Namespace, rule.Name, metrics.DirectionUp)
+	case res.Scaled:
+		metrics.RecordScale(rule.Namespace, rule.Name, metrics.DirectionDown)
+	case res.CooldownActive:
+		metrics.RecordCooldownSkip(rule.Namespace, rule.Name)
+	}
+}
+
+// scaleToZeroParams maps the scale-to-zero spec, applying the CRD defaults for rules created
+// before they existed.
+func scaleToZeroParams(stz *scalingv1.ScaleToZero, idleSince *metav1.Time) *internalTypes.ScaleToZeroParams {
+	p := &internalTypes.ScaleToZeroParams{
+		ActivationThreshold: max(stz.ActivationThreshold, 1),
+		ActivationReplicas:  max(stz.ActivationReplicas, 1),
+		IdleAfter:           time.Duration(stz.IdleSeconds) * time.Second,
+	}
+	if idleSince != nil {
+		p.IdleSince = idleSince.Time
+	}
+	return p
+}
+
+// predictionParams converts the prediction of the spec. Samples are taken once per poll, those older than
+// twice the time Samples polls take are dropped, so that the fit doesn't span a gap in the history.
+func predictionParams(p *scalingv1.Prediction, pollIntervalSeconds int) *internalTypes.PredictionParams {
+	samples, lookAhead := int(p.Samples), time.Duration(p.LookAheadSeconds)*time.Second
+	if samples < 2 {
+		samples = 10
+	}
+	if lookAhead <= 0 {
+		lookAhead = time.Minute
+	}
+	return &internalTypes.PredictionParams{
+		Model:     internalTypes.PredictionModel(p.Model),
+		Samples:   samples,
+		LookAhead: lookAhead,
+		MaxAge:    2 * time.Duration(samples*pollIntervalSeconds) * time.Second,
+		Interval:  time.Duration(pollIntervalSeconds) * time.Second,
+	}
+}
+
+// rateQuantity converts a rate in messages per second to a quantity, rounded to the milli.
+func rateQuantity(rate float64) *resource.Quantity {
+	return resource.NewMilliQuantity(int64(math.Round(rate*1000)), resource.DecimalSI)
+}
+
+func stabilizationWindow(p *scalingv1.StabilizationPolicy) time.Duration {
+	if p == nil || p.StabilizationWindowSeconds == nil {
+		return 0
+	}
+	return time.Duration(*p.StabilizationWindowSeconds) * time.Second
+}
+
+// scaleTarget resolves the workload referenced by the spec.
+func scaleTarget(spec scalingv1.ScalingRuleSpec) (internalTypes.ScaleTarget, error) {
+	ref := spec.ScaleTarget()
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return internalTypes.ScaleTarget{}, fmt.Errorf("invalid scaleTargetRef apiVersion %q: %w", ref.APIVersion, err)
+	}
+	return internalTypes.ScaleTarget{
+		GroupVersionKind: gv.WithKind(ref.Kind),
+		NamespacedName:   types.NamespacedName{Name: ref.Name, Namespace: spec.Namespace},
+	}, nil
+}
+
+// setCondition sets a status condition on the rule, stamped with the rule's current generation.
+func setCondition(rule *scalingv1.ScalingRule, condType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&rule.Status.Conditions, metav1.Condition{
+		Type:               condType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: rule.Generation,
+	})
+}
+
+// updateStatus patches the rule status through the status subresource. A merge patch is used so that
+// concurrent spec edits don't drop the status, which also carries the last scale time used for cooldown.
+// Failures are only logged, since the next poll will write the status again.
+func (r *ScalingRuleReconciler) updateStatus(ctx context.Context, base, rule *scalingv1.ScalingRule) {
+	rule.Status.ObservedGeneration = rule.Generation
+	if err := r.Status().Patch(ctx, rule, client.MergeFrom(base)); err != nil {
+		logf.FromContext(ctx).Error(err, "failed to update ScalingRule status")
+	}
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ScalingRuleReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&scalingv1.ScalingRule{}).
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &scalingv1.ScalingRule{}, authSecretIndexKey,
+		func(obj client.Object) []string {
+			ref := obj.(*scalingv1.ScalingRule).Spec.NatsAuthSecretRef
+			if ref == nil {
+				return nil
+			}
+			return []string{ref.Name}
+		}); err != nil {
+		return fmt.Errorf("failed to index ScalingRules by auth secret: %w", err)
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &scalingv1.ScalingRule{}, connectionIndexKey,
+		func(obj client.Object) []string {
+			ref := obj.(*scalingv1.ScalingRule).Spec.NatsConnectionRef
+			if ref == nil {
+				return nil
+			}
+			return []string{connectionIndexValue(connectionKind(ref), ref.Name)}
+		}); err != nil {
+		return fmt.Errorf("failed to index ScalingRules by NATS connection: %w", err)
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &scalingv1.ScalingRule{}, targetIndexKey,
+		indexByTarget); err != nil {
+		return fmt.Errorf("failed to index ScalingRules by scale target: %w", err)
+	}
+
+	deployments := appsv1.SchemeGroupVersion.WithKind("Deployment").GroupKind()
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		// status writes must not trigger a reconcile, polling is driven by RequeueAfter
+		For(&scalingv1.ScalingRule{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// rotated credentials and edited connections are applied right away instead of on the next poll. Only
+		// the metadata of Secrets is cached, the referenced ones are read from the API server.
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.rulesForSecret), builder.OnlyMetadata).
+		Watches(&scalingv1.NatsConnection{}, handler.EnqueueRequestsFromMapFunc(r.rulesForConnection),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&scalingv1.ClusterNatsConnection{}, handler.EnqueueRequestsFromMapFunc(r.rulesForConnection),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// a manual scale, a rollout or a deleted target is reflected right away. Only the metadata of the
+		// targets is cached, spec changes show up as a new generation.
+		Watches(&appsv1.Deployment{}, handler.EnqueueRequestsFromMapFunc(r.rulesForTarget(deployments)),
+			builder.OnlyMetadata, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// conflicts are resolved as soon as the other autoscaler goes away
+		Watches(&autoscalingv2.HorizontalPodAutoscaler{}, handler.EnqueueRequestsFromMapFunc(r.rulesForHPA),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&scalingv1.ScalingRule{}, handler.EnqueueRequestsFromMapFunc(r.rulesSharingTarget),
+			builder.WithPredicates(predicate.Funcs{
+				UpdateFunc:  func(event.UpdateEvent) bool { return false },
+				GenericFunc: func(event.GenericEvent) bool { return false },
+			})).
 		Named("scalingrule").
-		Complete(r)
+		Build(r)
+	if err != nil {
+		return err
+	}
+	r.controller, r.cache = c, mgr.GetCache()
+	r.watchedTargets = map[schema.GroupKind]struct{}{deployments: {}}
+	r.unwatchedTargets = make(map[schema.GroupKind]time.Time)
+	return nil
+}
+
+// watchTarget starts watching the kind of a scale target the first time a rule references it, so that
+// changes to targets other than Deployments trigger a reconcile too. The informer syncs in the
+// background, the reconcile doesn't wait for it. Kinds the controller has no RBAC for, such as custom
+// workloads, fail to sync: their watch is dropped and retried after targetWatchRetryInterval, their
+// targets are still picked up on every poll.
+func (r *ScalingRuleReconciler) watchTarget(ctx context.Context, gvk schema.GroupVersionKind) error {
+	if r.controller == nil {
+		// not set up with a manager
+		return nil
+	}
+	r.watchMu.Lock()
+	defer r.watchMu.Unlock()
+	gk := gvk.GroupKind()
+	if _, ok := r.watchedTargets[gk]; ok {
+		return nil
+	}
+	if retryAt, ok := r.unwatchedTargets[gk]; ok && time.Now().Before(retryAt) {
+		return nil
+	}
+	// an unknown kind would make the informer retry forever, the scaler reports it as not found instead
+	if _, err := r.RESTMapper().RESTMapping(gk, gvk.Version); err != nil {
+		return fmt.Errorf("failed to map %s: %w", gk, err)
+	}
+	obj := &metav1.PartialObjectMetadata{}
+	obj.SetGroupVersionKind(gvk)
+	src := source.Kind[client.Object](r.cache, obj, handler.EnqueueRequestsFromMapFunc(r.rulesForTarget(gk)),
+		predicate.GenerationChangedPredicate{})
+	if err := r.controller.Watch(src); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", gk, err)
+	}
+	delete(r.unwatchedTargets, gk)
+	r.watchedTargets[gk] = struct{}{}
+
+	logger := logf.FromContext(ctx).WithValues("kind", gk)
+	go func() {
+		syncCtx, cancel := context.WithTimeout(context.Background(), targetWatchSyncTimeout)
+		defer cancel()
+		// on timeout WaitForSync stops the source, removing the informer stops it from retrying the list
+		err := src.WaitForSync(syncCtx)
+		if err == nil {
+			return
+		}
+		logger.Error(err, "failed to sync scale target kind, is the controller allowed to list and watch it?",
+			"retryIn", targetWatchRetryInterval)
+		r.watchMu.Lock()
+		defer r.watchMu.Unlock()
+		if rmErr := r.cache.RemoveInformer(context.Background(), obj); rmErr != nil {
+			logger.Error(rmErr, "failed to remove the informer of scale target kind")
+		}
+		delete(r.watchedTargets, gk)
+		r.unwatchedTargets[gk] = time.Now().Add(targetWatchRetryInterval)
+	}()
+	return nil
+}
+
+func targetIndexValue(gk schema.GroupKind, namespace, name string) string {
+	return gk.Group + "/" + gk.Kind + "/" + namespace + "/" + name
+}
+
+// indexByTarget returns the index values of a rule under targetIndexKey.
+func indexByTarget(obj client.Object) []string {
+	target, err := scaleTarget(obj.(*scalingv1.ScalingRule).Spec)
+	if err != nil {
+		return nil
+	}
+	return []string{targetIndexValue(target.GroupVersionKind.GroupKind(), target.Namespace, target.Name)}
+}
+
+// rulesForTarget maps a workload of the given kind to the rules scaling it, in any namespace.
+func (r *ScalingRuleReconciler) rulesForTarget(gk schema.GroupKind) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		return r.rulesScaling(ctx, targetIndexValue(gk, obj.GetNamespace(), obj.GetName()), nil)
+	}
+}
+
+// rulesForHPA maps a HorizontalPodAutoscaler to the rules scaling its target, so that they notice the
+// conflict as soon as it is created and resume scaling once it is deleted.
+func (r *ScalingRuleReconciler) rulesForHPA(ctx context.Context, obj client.Object) []reconcile.Request {
+	hpa := obj.(*autoscalingv2.HorizontalPodAutoscaler)
+	gk, ok := hpaTarget(hpa)
+	if !ok {
+		return nil
+	}
+	return r.rulesScaling(ctx, targetIndexValue(gk, hpa.Namespace, hpa.Spec.ScaleTargetRef.Name), nil)
+}
+
+// rulesSharingTarget maps a rule to the other rules scaling its target, so that the newer rules are
+// re-evaluated when the rule they conflict with is created or deleted.
+func (r *ScalingRuleReconciler) rulesSharingTarget(ctx context.Context, obj client.Object) []reconcile.Request {
+	values := indexByTarget(obj)
+	if len(values) == 0 {
+		return nil
+	}
+	return r.rulesScaling(ctx, values[0], obj)
+}
+
+// rulesScaling returns the rules indexed under the target index value, except the excluded one.
+func (r *ScalingRuleReconciler) rulesScaling(ctx context.Context, target string, exclude client.Object) []reconcile.Request {
+	var rules scalingv1.ScalingRuleList
+	if err := r.List(ctx, &rules, client.MatchingFields{targetIndexKey: target}); err != nil {
+		logf.FromContext(ctx).Error(err, "failed to list ScalingRules of scale target", "target", target)
+		return nil
+	}
+	reqs := make([]reconcile.Request, 0, len(rules.Items))
+	for _, rule := range rules.Items {
+		key := client.ObjectKeyFromObject(&rule)
+		if exclude != nil && key == client.ObjectKeyFromObject(exclude) {
+			continue
+		}
+		reqs = append(reqs, reconcile.Request{NamespacedName: key})
+	}
+	return reqs
+}
+
+// conflict looks for other autoscalers of the target: a HorizontalPodAutoscaler, or a ScalingRule created
+// before the rule. It returns the condition reason and a message naming the conflicting autoscaler, or an
+// empty message when the rule is free to scale the target.
+func (r *ScalingRuleReconciler) conflict(ctx context.Context, rule *scalingv1.ScalingRule, target internalTypes.ScaleTarget) (string, string, error) {
+	gk := target.GroupVersionKind.GroupKind()
+
+	// an HPA only scales targets in its own namespace, and there are few per namespace
+	var hpas autoscalingv2.HorizontalPodAutoscalerList
+	if err := r.List(ctx, &hpas, client.InNamespace(target.Namespace)); err != nil {
+		return "", "", fmt.Errorf("failed to list HorizontalPodAutoscalers: %w", err)
+	}
+	for _, hpa := range hpas.Items {
+		if hpaGK, ok := hpaTarget(&hpa); ok && hpaGK == gk && hpa.Spec.ScaleTargetRef.Name == target.Name {
+			return reasonHPAConflict, fmt.Sprintf("HorizontalPodAutoscaler %s/%s also scales %s %s",
+				hpa.Namespace, hpa.Name, gk.Kind, target.NamespacedName), nil
+		}
+	}
+
+	var rules scalingv1.ScalingRuleList
+	if err := r.List(ctx, &rules, client.MatchingFields{
+		targetIndexKey: targetIndexValue(gk, target.Namespace, target.Name),
+	}); err != nil {
+		return "", "", fmt.Errorf("failed to list ScalingRules of the scale target: %w", err)
+	}
+	for _, other := range rules.Items {
+		if olderRule(&other, rule) {
+			return reasonRuleConflict, fmt.Sprintf("ScalingRule %s/%s, created first, also scales %s %s",
+				other.Namespace, other.Name, gk.Kind, target.NamespacedName), nil
+		}
+	}
+	return "", "", nil
+}
+
+// olderRule reports whether a was created before b. Rules created in the same second are ordered by
+// namespace and name, so that exactly one of the rules sharing a target keeps scaling it.
+func olderRule(a, b *scalingv1.ScalingRule) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
+
+// hpaTarget returns the group and kind of the HPA's scale target.
+func hpaTarget(hpa *autoscalingv2.HorizontalPodAutoscaler) (schema.GroupKind, bool) {
+	ref := hpa.Spec.ScaleTargetRef
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return schema.GroupKind{}, false
+	}
+	return schema.GroupKind{Group: gv.Group, Kind: ref.Kind}, true
+}
+
+func connectionIndexValue(kind, name string) string {
+	return kind + "/" + name
+}
+
+// rulesForSecret maps a Secret to the rules that read their NATS credentials from it, directly or through
+// the connection they reference.
+func (r *ScalingRuleReconciler) rulesForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	logger := logf.FromContext(ctx).WithValues("secret", client.ObjectKeyFromObject(obj))
+	var rules scalingv1.ScalingRuleList
+	if err := r.List(ctx, &rules, client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{authSecretIndexKey: obj.GetName()}); err != nil {
+		logger.Error(err, "failed to list ScalingRules of secret")
+		return nil
+	}
+	reqs := make([]reconcile.Request, 0, len(rules.Items))
+	for _, rule := range rules.Items {
+		reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&rule)})
+	}
+
+	namespaced, cluster, err := connectionsForSecret(ctx, r.Client, obj)
+	if err != nil {
+		logger.Error(err, "failed to list NATS connections of secret")
+		return reqs
+	}
+	for _, conn := range namespaced {
+		reqs = append(reqs, r.rulesForConnection(ctx, &conn)...)
+	}
+	for _, conn := range cluster {
+		reqs = append(reqs, r.rulesForConnection(ctx, &conn)...)
+	}
+	return reqs
+}
+
+// rulesForConnection maps a NatsConnection to the rules in its namespace referencing it, and a
+// ClusterNatsConnection to the rules in all namespaces referencing it.
+func (r *ScalingRuleReconciler) rulesForConnection(ctx context.Context, obj client.Object) []reconcile.Request {
+	kind := scalingv1.NatsConnectionKind
+	if _, ok := obj.(*scalingv1.ClusterNatsConnection); ok {
+		kind = scalingv1.ClusterNatsConnectionKind
+	}
+	var rules scalingv1.ScalingRuleList
+	if err := r.List(ctx, &rules, client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{connectionIndexKey: connectionIndexValue(kind, obj.GetName())}); err != nil {
+		logf.FromContext(ctx).Error(err, "failed to list ScalingRules of NATS connection", "kind", kind, "name", obj.GetName())
+		return nil
+	}
+	reqs := make([]reconcile.Request, 0, len(rules.Items))
+	for _, rule := range rules.Items {
+		reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&rule)})
+	}
+	return reqs
 }
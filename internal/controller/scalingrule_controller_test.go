@@ -27,13 +27,23 @@ import (
 	"github.com/Av1shay/nats-scaler/pkg/errs"
 
 	"github.com/Av1shay/nats-scaler/internal/nats"
+	internalTypes "github.com/Av1shay/nats-scaler/internal/types"
 	testutils "github.com/Av1shay/nats-scaler/test/utils"
 	"github.com/go-logr/logr"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
 	k8serrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	scalingv1 "github.com/Av1shay/nats-scaler/api/v1"
@@ -46,12 +56,18 @@ var _ = Describe("ScalingRule Controller", func() {
 
 		ctx := context.Background()
 
+		var recorder *record.FakeRecorder
+		BeforeEach(func() {
+			recorder = record.NewFakeRecorder(10)
+		})
+
 		It("should successfully reconcile the resource", func() {
 			By("Create the necessary resources")
 
 			natsServer := &mockNatsServer{
 				resp: nats.JszResponse{
 					AccountDetails: []nats.AccountDetails{{
+						Name: nats.GlobalAccountName,
 						StreamDetail: []nats.StreamDetail{
 							{Name: "ORDERS", ConsumerDetail: []nats.ConsumerDetail{{
 								Name:       "orders-consumer",
@@ -68,7 +84,13 @@ var _ = Describe("ScalingRule Controller", func() {
 			DeferCleanup(ts.Close)
 
 			natsService := nats.NewService(&http.Client{Timeout: 5 * time.Second})
-			mockedScaler := &mockScaler{}
+			mockedScaler := &mockScaler{res: internalTypes.ScaleResult{
+				TargetUID:       "target-uid",
+				CurrentReplicas: 2,
+				DesiredReplicas: 1,
+				Scaled:          true,
+				LastScaleTime:   time.Now(),
+			}}
 
 			resourceName := fmt.Sprintf("test-resource-%d", GinkgoParallelProcess())
 			typeNamespacedName := types.NamespacedName{
@@ -82,7 +104,7 @@ var _ = Describe("ScalingRule Controller", func() {
 				err := k8sClient.Get(ctx, typeNamespacedName, resource)
 				Expect(err).NotTo(HaveOccurred())
 				By("Cleanup the specific resource instance ScalingRule")
-				Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+				deleteScalingRule(resource)
 			})
 
 			By("Reconciling the created resource")
@@ -91,6 +113,7 @@ var _ = Describe("ScalingRule Controller", func() {
 				Scheme:      k8sClient.Scheme(),
 				NatsService: natsService,
 				Scaler:      mockedScaler,
+				Recorder:    recorder,
 			}
 
 			res, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
@@ -101,13 +124,40 @@ var _ = Describe("ScalingRule Controller", func() {
 			Expect(res.RequeueAfter).To(Equal(time.Duration(spec.PollIntervalSeconds) * time.Second))
 
 			By("Asserting the scaler was called with the expected parameters")
-			Expect(mockedScaler.calledWith.Nn).To(Equal(types.NamespacedName{
-				Name:      spec.DeploymentName,
-				Namespace: spec.Namespace,
+			Expect(mockedScaler.calledWith.Target).To(Equal(internalTypes.ScaleTarget{
+				GroupVersionKind: appsv1.SchemeGroupVersion.WithKind("Deployment"),
+				NamespacedName: types.NamespacedName{
+					Name:      spec.DeploymentName,
+					Namespace: spec.Namespace,
+				},
 			}))
 			Expect(mockedScaler.calledWith.Spec.MinReplicas).To(Equal(spec.MinReplicas))
 			Expect(mockedScaler.calledWith.Spec.MaxReplicas).To(Equal(spec.MaxReplicas))
-			Expect(mockedScaler.calledWith.Pending).To(Equal(2))
+			Expect(mockedScaler.calledWith.Spec.Triggers[0].Value).To(Equal(2))
+
+			By("Asserting the status was updated")
+			updated := &scalingv1.ScalingRule{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			// the rule only sets the deprecated deploymentName, which the webhook would have migrated
+			Expect(updated.Status.ScaleTarget).To(Equal(&scalingv1.ScaleTargetRef{
+				APIVersion: "apps/v1", Kind: "Deployment", Name: spec.DeploymentName,
+			}))
+			Expect(updated.Status.LastObservedPending).To(Equal(2))
+			Expect(updated.Status.CurrentReplicas).To(Equal(int32(2)))
+			Expect(updated.Status.DesiredReplicas).To(Equal(int32(1)))
+			Expect(updated.Status.LastScaleTime).NotTo(BeNil())
+			Expect(updated.Status.ObservedGeneration).To(Equal(updated.Generation))
+			Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, scalingv1.ConditionReady)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, scalingv1.ConditionNatsReachable)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, scalingv1.ConditionTargetFound)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, scalingv1.ConditionScalingActive)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(updated.Status.Conditions, scalingv1.ConditionScalingLimited)).To(BeTrue())
+
+			By("Asserting the scale was recorded on the rule and the target")
+			Expect(recorder.Events).To(HaveLen(2))
+			Expect(<-recorder.Events).To(Equal("Normal ScaledDown Scaled Deployment test-deployment from 2 to 1 replicas (pending: 2)"))
+			Expect(<-recorder.Events).To(Equal(fmt.Sprintf(
+				"Normal ScaledDown Scaled Deployment test-deployment from 2 to 1 replicas (pending: 2) (ScalingRule %s)", resourceName)))
 		})
 
 		It("should produce correct error on NATS unexpected status code", func() {
@@ -136,7 +186,7 @@ var _ = Describe("ScalingRule Controller", func() {
 				err := k8sClient.Get(ctx, typeNamespacedName, resource)
 				Expect(err).NotTo(HaveOccurred())
 				By("Cleanup the specific resource instance ScalingRule")
-				Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+				deleteScalingRule(resource)
 			})
 
 			By("Reconciling the created resource")
@@ -145,6 +195,7 @@ var _ = Describe("ScalingRule Controller", func() {
 				Scheme:      k8sClient.Scheme(),
 				NatsService: natsService,
 				Scaler:      mockedScaler,
+				Recorder:    recorder,
 			}
 
 			logger := &testutils.FakeLogger{}
@@ -164,6 +215,13 @@ var _ = Describe("ScalingRule Controller", func() {
 			Expect(errors.As(logger.Err, &httpErr)).To(BeTrue(), "expected error to be of type *HTTPStatusCodeErr")
 			Expect(httpErr.Code).To(Equal(500))
 			Expect(string(httpErr.Body)).To(Equal("{\"account_details\":null}\n"))
+
+			By("Asserting the status reports NATS as unreachable")
+			updated := &scalingv1.ScalingRule{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(meta.IsStatusConditionFalse(updated.Status.Conditions, scalingv1.ConditionNatsReachable)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(updated.Status.Conditions, scalingv1.ConditionReady)).To(BeTrue())
+			Expect(<-recorder.Events).To(HavePrefix("Warning NatsUnreachable"))
 		})
 
 		It("should produce correct error on scaling error", func() {
@@ -172,6 +230,7 @@ var _ = Describe("ScalingRule Controller", func() {
 			natsServer := &mockNatsServer{
 				resp: nats.JszResponse{
 					AccountDetails: []nats.AccountDetails{{
+						Name: nats.GlobalAccountName,
 						StreamDetail: []nats.StreamDetail{
 							{Name: "ORDERS", ConsumerDetail: []nats.ConsumerDetail{{
 								Name:       "orders-consumer",
@@ -204,7 +263,7 @@ var _ = Describe("ScalingRule Controller", func() {
 				err := k8sClient.Get(ctx, typeNamespacedName, resource)
 				Expect(err).NotTo(HaveOccurred())
 				By("Cleanup the specific resource instance ScalingRule")
-				Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+				deleteScalingRule(resource)
 			})
 
 			By("Reconciling the created resource")
@@ -213,6 +272,7 @@ var _ = Describe("ScalingRule Controller", func() {
 				Scheme:      k8sClient.Scheme(),
 				NatsService: natsService,
 				Scaler:      mockedScaler,
+				Recorder:    recorder,
 			}
 
 			logger := &testutils.FakeLogger{}
@@ -226,30 +286,1159 @@ var _ = Describe("ScalingRule Controller", func() {
 			Expect(errors.Is(logger.Err, expectedErr)).To(BeTrue())
 
 			By("Asserting the scaler was called with the expected parameters")
-			Expect(mockedScaler.calledWith.Nn).To(Equal(types.NamespacedName{
-				Name:      spec.DeploymentName,
-				Namespace: spec.Namespace,
+			Expect(mockedScaler.calledWith.Target).To(Equal(internalTypes.ScaleTarget{
+				GroupVersionKind: appsv1.SchemeGroupVersion.WithKind("Deployment"),
+				NamespacedName: types.NamespacedName{
+					Name:      spec.DeploymentName,
+					Namespace: spec.Namespace,
+				},
 			}))
 			Expect(mockedScaler.calledWith.Spec.MinReplicas).To(Equal(spec.MinReplicas))
 			Expect(mockedScaler.calledWith.Spec.MaxReplicas).To(Equal(spec.MaxReplicas))
-			Expect(mockedScaler.calledWith.Pending).To(Equal(15))
+			Expect(mockedScaler.calledWith.Spec.Triggers[0].Value).To(Equal(15))
 		})
-	})
-})
 
-func defaultNatsSpecs(depName, ns, natsURL string) scalingv1.ScalingRuleSpec {
-	return scalingv1.ScalingRuleSpec{
-		DeploymentName:      depName,
-		Namespace:           ns,
-		MinReplicas:         1,
-		MaxReplicas:         3,
-		NatsMonitoringURL:   natsURL,
-		StreamName:          "ORDERS",
-		ConsumerName:        "orders-consumer",
-		ScaleUpThreshold:    10,
-		ScaleDownThreshold:  2,
-		PollIntervalSeconds: 10,
-	}
+		It("should pass the persisted last scale time to the scaler", func() {
+			By("Create the necessary resources")
+			mockedScaler := &mockScaler{}
+
+			resourceName := fmt.Sprintf("test-resource-%d", GinkgoParallelProcess())
+			typeNamespacedName := types.NamespacedName{
+				Name:      resourceName,
+				Namespace: nsName,
+			}
+			spec := defaultNatsSpecs(depName, nsName, "http://nats:8222")
+			createDummyScalingRuleSpec(typeNamespacedName, spec)
+			DeferCleanup(func() {
+				resource := &scalingv1.ScalingRule{}
+				err := k8sClient.Get(ctx, typeNamespacedName, resource)
+				Expect(err).NotTo(HaveOccurred())
+				By("Cleanup the specific resource instance ScalingRule")
+				deleteScalingRule(resource)
+			})
+
+			By("Recording a scale in the status, as a previous leader would have")
+			lastScale := metav1.NewTime(time.Now().Add(-5 * time.Second).Truncate(time.Second))
+			resource := &scalingv1.ScalingRule{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Status.LastScaleTime = &lastScale
+			Expect(k8sClient.Status().Update(ctx, resource)).To(Succeed())
+
+			By("Reconciling the created resource")
+			controllerReconciler := &ScalingRuleReconciler{
+				Client:      k8sClient,
+				Scheme:      k8sClient.Scheme(),
+				NatsService: &mockNatsBackend{state: nats.ConsumerState{NumPending: 20}},
+				Scaler:      mockedScaler,
+				Recorder:    recorder,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Asserting the scaler received the last scale time")
+			Expect(mockedScaler.calledWith.Spec.LastScaleTime.Equal(lastScale.Time)).To(BeTrue())
+		})
+
+		It("should query the JetStream backend when selected", func() {
+			By("Create the necessary resources")
+			jetStreamService := &mockNatsBackend{state: nats.ConsumerState{NumPending: 7}}
+			mockedScaler := &mockScaler{}
+
+			resourceName := fmt.Sprintf("test-resource-%d", GinkgoParallelProcess())
+			typeNamespacedName := types.NamespacedName{
+				Name:      resourceName,
+				Namespace: nsName,
+			}
+			spec := defaultNatsSpecs(depName, nsName, "")
+			spec.NatsBackend = scalingv1.NatsBackendJetStream
+			spec.NatsURL = "nats://nats:4222"
+			createDummyScalingRuleSpec(typeNamespacedName, spec)
+			DeferCleanup(func() {
+				resource := &scalingv1.ScalingRule{}
+				err := k8sClient.Get(ctx, typeNamespacedName, resource)
+				Expect(err).NotTo(HaveOccurred())
+				By("Cleanup the specific resource instance ScalingRule")
+				deleteScalingRule(resource)
+			})
+
+			By("Reconciling the created resource")
+			controllerReconciler := &ScalingRuleReconciler{
+				Client:           k8sClient,
+				Scheme:           k8sClient.Scheme(),
+				NatsService:      &mockNatsBackend{err: errors.New("monitoring backend must not be used")},
+				JetStreamService: jetStreamService,
+				Scaler:           mockedScaler,
+				Recorder:         recorder,
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Asserting the JetStream backend was queried")
+			Expect(jetStreamService.calledWith.URL).To(Equal("nats://nats:4222"))
+			Expect(jetStreamService.calledWith.Stream).To(Equal("ORDERS"))
+			Expect(jetStreamService.calledWith.Consumer).To(Equal("orders-consumer"))
+			Expect(mockedScaler.calledWith.Spec.Triggers[0].Value).To(Equal(7))
+		})
+
+		It("should pass all monitoring endpoints to the backend", func() {
+			By("Create the necessary resources")
+			backend := &mockNatsBackend{state: nats.ConsumerState{NumPending: 7}}
+			mockedScaler := &mockScaler{}
+
+			resourceName := fmt.Sprintf("test-resource-%d", GinkgoParallelProcess())
+			typeNamespacedName := types.NamespacedName{
+				Name:      resourceName,
+				Namespace: nsName,
+			}
+			spec := defaultNatsSpecs(depName, nsName, "http://nats-0:8222")
+			spec.NatsMonitoringURLs = []string{"http://nats-1:8222", "http://nats-2:8222"}
+			spec.NatsFailover = scalingv1.FailoverPolicyRoundRobin
+			createDummyScalingRuleSpec(typeNamespacedName, spec)
+			DeferCleanup(func() {
+				resource := &scalingv1.ScalingRule{}
+				err := k8sClient.Get(ctx, typeNamespacedName, resource)
+				Expect(err).NotTo(HaveOccurred())
+				By("Cleanup the specific resource instance ScalingRule")
+				deleteScalingRule(resource)
+			})
+
+			controllerReconciler := &ScalingRuleReconciler{
+				Client:      k8sClient,
+				Scheme:      k8sClient.Scheme(),
+				NatsService: backend,
+				Scaler:      mockedScaler,
+				Recorder:    recorder,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(backend.calledWith.URL).To(Equal("http://nats-0:8222"))
+			Expect(backend.calledWith.FallbackURLs).To(Equal([]string{"http://nats-1:8222", "http://nats-2:8222"}))
+			Expect(backend.calledWith.RoundRobin).To(BeTrue())
+		})
+
+		It("should fall back to max replicas after consecutive NATS failures", func() {
+			By("Create the necessary resources")
+			backend := &mockNatsBackend{err: errors.New("connection refused")}
+			mockedScaler := &mockScaler{res: internalTypes.ScaleResult{
+				CurrentReplicas: 1, DesiredReplicas: 3, Scaled: true, Fallback: true, LastScaleTime: time.Now(),
+			}}
+
+			resourceName := fmt.Sprintf("test-resource-%d", GinkgoParallelProcess())
+			typeNamespacedName := types.NamespacedName{
+				Name:      resourceName,
+				Namespace: nsName,
+			}
+			spec := defaultNatsSpecs(depName, nsName, "http://nats:8222")
+			spec.Fallback = &scalingv1.Fallback{Action: scalingv1.FallbackActionMax, FailureThreshold: 2}
+			createDummyScalingRuleSpec(typeNamespacedName, spec)
+			DeferCleanup(func() {
+				resource := &scalingv1.ScalingRule{}
+				err := k8sClient.Get(ctx, typeNamespacedName, resource)
+				Expect(err).NotTo(HaveOccurred())
+				By("Cleanup the specific resource instance ScalingRule")
+				deleteScalingRule(resource)
+			})
+
+			controllerReconciler := &ScalingRuleReconciler{
+				Client:      k8sClient,
+				Scheme:      k8sClient.Scheme(),
+				NatsService: backend,
+				Scaler:      mockedScaler,
+				Recorder:    recorder,
+			}
+			reconcileAndGet := func() *scalingv1.ScalingRule {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+				Expect(err).NotTo(HaveOccurred())
+				updated := &scalingv1.ScalingRule{}
+				Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+				return updated
+			}
+
+			By("Holding the replicas below the failure threshold")
+			updated := reconcileAndGet()
+			Expect(updated.Status.ConsecutiveFailures).To(Equal(int32(1)))
+			Expect(updated.Status.LastFailureTime).NotTo(BeNil())
+			Expect(mockedScaler.calledWith.Spec.FallbackReplicas).To(BeNil())
+			cond := meta.FindStatusCondition(updated.Status.Conditions, scalingv1.ConditionScalingActive)
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+
+			By("Not counting a reconcile triggered by the target before the next poll")
+			updated = reconcileAndGet()
+			Expect(updated.Status.ConsecutiveFailures).To(Equal(int32(1)))
+			Expect(mockedScaler.calledWith.Spec.FallbackReplicas).To(BeNil())
+
+			By("Scaling to max replicas once the threshold is reached")
+			updated.Status.LastFailureTime = &metav1.Time{Time: time.Now().Add(-time.Duration(spec.PollIntervalSeconds) * time.Second)}
+			Expect(k8sClient.Status().Update(ctx, updated)).To(Succeed())
+			updated = reconcileAndGet()
+			Expect(updated.Status.ConsecutiveFailures).To(Equal(int32(2)))
+			Expect(mockedScaler.calledWith.Spec.FallbackReplicas).NotTo(BeNil())
+			Expect(*mockedScaler.calledWith.Spec.FallbackReplicas).To(Equal(int32(3)))
+			Expect(updated.Status.DesiredReplicas).To(Equal(int32(3)))
+			cond = meta.FindStatusCondition(updated.Status.Conditions, scalingv1.ConditionScalingActive)
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			Expect(cond.Reason).To(Equal(reasonFallback))
+
+			By("Resetting the failure count once NATS responds")
+			backend.err = nil
+			mockedScaler.res = internalTypes.ScaleResult{CurrentReplicas: 3, DesiredReplicas: 3}
+			updated = reconcileAndGet()
+			Expect(updated.Status.ConsecutiveFailures).To(BeZero())
+			Expect(updated.Status.LastFailureTime).To(BeNil())
+			Expect(mockedScaler.calledWith.Spec.FallbackReplicas).To(BeNil())
+		})
+
+		It("should hold the replicas without falling back when the consumer isn't found", func() {
+			By("Create the necessary resources")
+			backend := &mockNatsBackend{err: &errs.ConsumerNotFoundErr{Account: nats.GlobalAccountName, Stream: "EVENTS", Consumer: "xxx"}}
+			mockedScaler := &mockScaler{}
+
+			resourceName := fmt.Sprintf("test-resource-%d", GinkgoParallelProcess())
+			typeNamespacedName := types.NamespacedName{
+				Name:      resourceName,
+				Namespace: nsName,
+			}
+			spec := defaultNatsSpecs(depName, nsName, "http://nats:8222")
+			spec.Fallback = &scalingv1.Fallback{Action: scalingv1.FallbackActionMax, FailureThreshold: 1}
+			createDummyScalingRuleSpec(typeNamespacedName, spec)
+			DeferCleanup(func() {
+				resource := &scalingv1.ScalingRule{}
+				err := k8sClient.Get(ctx, typeNamespacedName, resource)
+				Expect(err).NotTo(HaveOccurred())
+				By("Cleanup the specific resource instance ScalingRule")
+				deleteScalingRule(resource)
+			})
+
+			controllerReconciler := &ScalingRuleReconciler{
+				Client:      k8sClient,
+				Scheme:      k8sClient.Scheme(),
+				NatsService: backend,
+				Scaler:      mockedScaler,
+				Recorder:    recorder,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(Receive(ContainSubstring(eventConsumerNotFound)))
+
+			updated := &scalingv1.ScalingRule{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.ConsecutiveFailures).To(BeZero())
+			Expect(mockedScaler.calledWith.Target).To(BeZero())
+			cond := meta.FindStatusCondition(updated.Status.Conditions, scalingv1.ConditionNatsReachable)
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(reasonConsumerNotFound))
+			cond = meta.FindStatusCondition(updated.Status.Conditions, scalingv1.ConditionScalingActive)
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(reasonConsumerNotFound))
+
+			By("Reporting an unknown account as such")
+			backend.err = &errs.AccountNotFoundErr{Account: "ORDERS"}
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(Receive(ContainSubstring(eventAccountNotFound)))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			cond = meta.FindStatusCondition(updated.Status.Conditions, scalingv1.ConditionNatsReachable)
+			Expect(cond.Reason).To(Equal(reasonAccountNotFound))
+			Expect(mockedScaler.calledWith.Target).To(BeZero())
+		})
+
+		It("should not poll a rule with an invalid spec", func() {
+			By("Create the necessary resources")
+			backend := &mockNatsBackend{}
+			mockedScaler := &mockScaler{}
+
+			resourceName := fmt.Sprintf("test-resource-%d", GinkgoParallelProcess())
+			typeNamespacedName := types.NamespacedName{
+				Name:      resourceName,
+				Namespace: nsName,
+			}
+			// the webhook would reject the rule, the controller must not act on it either
+			spec := defaultNatsSpecs(depName, nsName, "http://nats:8222")
+			spec.MinReplicas = 4
+			createDummyScalingRuleSpec(typeNamespacedName, spec)
+			DeferCleanup(func() {
+				resource := &scalingv1.ScalingRule{}
+				err := k8sClient.Get(ctx, typeNamespacedName, resource)
+				Expect(err).NotTo(HaveOccurred())
+				By("Cleanup the specific resource instance ScalingRule")
+				deleteScalingRule(resource)
+			})
+
+			controllerReconciler := &ScalingRuleReconciler{
+				Client:      k8sClient,
+				Scheme:      k8sClient.Scheme(),
+				NatsService: backend,
+				Scaler:      mockedScaler,
+				Recorder:    recorder,
+			}
+			res, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(errRequeueIntervalLong))
+			Expect(recorder.Events).To(Receive(ContainSubstring(eventInvalidSpec)))
+			Expect(backend.calledWith).To(BeZero())
+			Expect(mockedScaler.calledWith.Target).To(BeZero())
+
+			updated := &scalingv1.ScalingRule{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			cond := meta.FindStatusCondition(updated.Status.Conditions, scalingv1.ConditionReady)
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(reasonInvalidSpec))
+			Expect(cond.Message).To(ContainSubstring("spec.minReplicas"))
+		})
+
+		It("should scale on the selected consumer metric", func() {
+			By("Create the necessary resources")
+			mockedScaler := &mockScaler{}
+
+			resourceName := fmt.Sprintf("test-resource-%d", GinkgoParallelProcess())
+			typeNamespacedName := types.NamespacedName{
+				Name:      resourceName,
+				Namespace: nsName,
+			}
+			spec := defaultNatsSpecs(depName, nsName, "http://nats:8222")
+			spec.Metric = scalingv1.ScalingMetricWeighted
+			spec.MetricWeights = &scalingv1.MetricWeights{Pending: 1, AckPending: 2}
+			createDummyScalingRuleSpec(typeNamespacedName, spec)
+			DeferCleanup(func() {
+				resource := &scalingv1.ScalingRule{}
+				err := k8sClient.Get(ctx, typeNamespacedName, resource)
+				Expect(err).NotTo(HaveOccurred())
+				By("Cleanup the specific resource instance ScalingRule")
+				deleteScalingRule(resource)
+			})
+
+			By("Reconciling the created resource")
+			controllerReconciler := &ScalingRuleReconciler{
+				Client:      k8sClient,
+				Scheme:      k8sClient.Scheme(),
+				NatsService: &mockNatsBackend{state: nats.ConsumerState{NumPending: 10, NumAckPending: 5}},
+				Scaler:      mockedScaler,
+				Recorder:    recorder,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Asserting the scaler received the weighted sum")
+			Expect(mockedScaler.calledWith.Spec.Triggers[0].Value).To(Equal(20))
+			updated := &scalingv1.ScalingRule{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.LastObservedPending).To(Equal(10))
+		})
+
+		It("should scale on multiple triggers", func() {
+			By("Create the necessary resources")
+			backend := &mockNatsBackend{states: map[string]nats.ConsumerState{
+				"orders-consumer":   {NumPending: 30},
+				"payments-consumer": {NumPending: 4, NumAckPending: 6},
+			}}
+			mockedScaler := &mockScaler{res: internalTypes.ScaleResult{
+				CurrentReplicas: 1,
+				DesiredReplicas: 2,
+				Triggers: []internalTypes.TriggerResult{
+					{Name: "orders", Value: 30, DesiredReplicas: 2},
+					{Name: "payments", Value: 10, DesiredReplicas: 1},
+				},
+				ActiveTrigger: "orders",
+			}}
+
+			resourceName := fmt.Sprintf("test-resource-%d", GinkgoParallelProcess())
+			typeNamespacedName := types.NamespacedName{
+				Name:      resourceName,
+				Namespace: nsName,
+			}
+			spec := defaultNatsSpecs(depName, nsName, "http://nats:8222")
+			spec.StreamName, spec.ConsumerName = "", ""
+			spec.Triggers = []scalingv1.ScalingTrigger{
+				{Name: "orders", StreamName: "ORDERS", ConsumerName: "orders-consumer"},
+				{Name: "payments", StreamName: "PAYMENTS", ConsumerName: "payments-consumer", Metric: scalingv1.ScalingMetricWeighted,
+					MetricWeights: &scalingv1.MetricWeights{Pending: 1, AckPending: 1}, ScaleUpThreshold: 50, ScaleDownThreshold: 5},
+			}
+			createDummyScalingRuleSpec(typeNamespacedName, spec)
+			DeferCleanup(func() {
+				resource := &scalingv1.ScalingRule{}
+				err := k8sClient.Get(ctx, typeNamespacedName, resource)
+				Expect(err).NotTo(HaveOccurred())
+				By("Cleanup the specific resource instance ScalingRule")
+				deleteScalingRule(resource)
+			})
+
+			By("Reconciling the created resource")
+			controllerReconciler := &ScalingRuleReconciler{
+				Client:      k8sClient,
+				Scheme:      k8sClient.Scheme(),
+				NatsService: backend,
+				Scaler:      mockedScaler,
+				Recorder:    recorder,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Asserting the scaler received every trigger with its own thresholds")
+			Expect(mockedScaler.calledWith.Spec.Triggers).To(Equal([]internalTypes.Trigger{
+				{Name: "orders", Value: 30, Pending: 30, ScaleUpThreshold: spec.ScaleUpThreshold, ScaleDownThreshold: spec.ScaleDownThreshold},
+				{Name: "payments", Value: 10, Pending: 4, ScaleUpThreshold: 50, ScaleDownThreshold: 5},
+			}))
+
+			By("Asserting the status reports every trigger")
+			updated := &scalingv1.ScalingRule{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.LastObservedPending).To(Equal(34))
+			Expect(updated.Status.LastObservedValue).To(Equal(30))
+			Expect(updated.Status.Triggers).To(Equal([]scalingv1.TriggerStatus{
+				{Name: "orders", Pending: 30, Value: 30, DesiredReplicas: 2},
+				{Name: "payments", Pending: 4, Value: 10, DesiredReplicas: 1},
+			}))
+		})
+
+		It("should track the consumer idle time for scale to zero", func() {
+			By("Create the necessary resources")
+			backend := &mockNatsBackend{}
+			mockedScaler := &mockScaler{}
+
+			resourceName := fmt.Sprintf("test-resource-%d", GinkgoParallelProcess())
+			typeNamespacedName := types.NamespacedName{
+				Name:      resourceName,
+				Namespace: nsName,
+			}
+			spec := defaultNatsSpecs(depName, nsName, "http://nats:8222")
+			spec.MinReplicas = 0
+			spec.ScaleToZero = &scalingv1.ScaleToZero{ActivationThreshold: 5, ActivationReplicas: 2, IdleSeconds: 60}
+			createDummyScalingRuleSpec(typeNamespacedName, spec)
+			DeferCleanup(func() {
+				resource := &scalingv1.ScalingRule{}
+				err := k8sClient.Get(ctx, typeNamespacedName, resource)
+				Expect(err).NotTo(HaveOccurred())
+				By("Cleanup the specific resource instance ScalingRule")
+				deleteScalingRule(resource)
+			})
+
+			controllerReconciler := &ScalingRuleReconciler{
+				Client:      k8sClient,
+				Scheme:      k8sClient.Scheme(),
+				NatsService: backend,
+				Scaler:      mockedScaler,
+				Recorder:    recorder,
+			}
+			reconcileAndGet := func() *scalingv1.ScalingRule {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+				Expect(err).NotTo(HaveOccurred())
+				updated := &scalingv1.ScalingRule{}
+				Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+				return updated
+			}
+
+			By("Reconciling while messages are still being acknowledged")
+			backend.state = nats.ConsumerState{NumAckPending: 3}
+			Expect(reconcileAndGet().Status.IdleSince).To(BeNil())
+			Expect(mockedScaler.calledWith.Spec.ScaleToZero).NotTo(BeNil())
+			Expect(mockedScaler.calledWith.Spec.ScaleToZero.ActivationThreshold).To(Equal(5))
+			Expect(mockedScaler.calledWith.Spec.ScaleToZero.ActivationReplicas).To(Equal(int32(2)))
+			Expect(mockedScaler.calledWith.Spec.ScaleToZero.IdleAfter).To(Equal(time.Minute))
+			Expect(mockedScaler.calledWith.Spec.ScaleToZero.IdleSince.IsZero()).To(BeTrue())
+
+			By("Reconciling once the consumer is idle")
+			backend.state = nats.ConsumerState{}
+			idleSince := reconcileAndGet().Status.IdleSince
+			Expect(idleSince).NotTo(BeNil())
+			Expect(mockedScaler.calledWith.Spec.ScaleToZero.IdleSince.IsZero()).To(BeFalse())
+
+			By("Keeping the idle time on the next idle poll")
+			Expect(reconcileAndGet().Status.IdleSince.Equal(idleSince)).To(BeTrue())
+
+			By("Resetting it when messages arrive")
+			backend.state = nats.ConsumerState{NumPending: 1}
+			Expect(reconcileAndGet().Status.IdleSince).To(BeNil())
+		})
+
+		It("should pass the credentials of the auth secret to the backend", func() {
+			By("Create the necessary resources")
+			backend := &mockNatsBackend{state: nats.ConsumerState{NumPending: 3}}
+			mockedScaler := &mockScaler{}
+
+			resourceName := fmt.Sprintf("test-resource-%d", GinkgoParallelProcess())
+			typeNamespacedName := types.NamespacedName{
+				Name:      resourceName,
+				Namespace: nsName,
+			}
+			spec := defaultNatsSpecs(depName, nsName, "https://nats:8222")
+			spec.NatsAuthSecretRef = &scalingv1.SecretReference{Name: "nats-auth"}
+			createDummyScalingRuleSpec(typeNamespacedName, spec)
+			DeferCleanup(func() {
+				resource := &scalingv1.ScalingRule{}
+				err := k8sClient.Get(ctx, typeNamespacedName, resource)
+				Expect(err).NotTo(HaveOccurred())
+				By("Cleanup the specific resource instance ScalingRule")
+				deleteScalingRule(resource)
+			})
+
+			controllerReconciler := &ScalingRuleReconciler{
+				Client:      k8sClient,
+				Scheme:      k8sClient.Scheme(),
+				APIReader:   k8sClient,
+				NatsService: backend,
+				Scaler:      mockedScaler,
+				Recorder:    recorder,
+			}
+
+			By("Reconciling before the secret exists")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			updated := &scalingv1.ScalingRule{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			cond := meta.FindStatusCondition(updated.Status.Conditions, scalingv1.ConditionNatsReachable)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(reasonAuthSecretUnavailable))
+			Expect(recorder.Events).To(Receive(ContainSubstring(eventAuthSecretUnavailable)))
+			Expect(mockedScaler.calledWith.Spec.Triggers).To(BeEmpty())
+
+			By("Reconciling once the secret is created")
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "nats-auth", Namespace: nsName},
+				Data: map[string][]byte{
+					scalingv1.AuthSecretUsernameKey: []byte("scaler"),
+					scalingv1.AuthSecretPasswordKey: []byte("s3cr3t"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+			})
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backend.calledWith.Auth).NotTo(BeNil())
+			Expect(backend.calledWith.Auth.Username).To(Equal("scaler"))
+			Expect(backend.calledWith.Auth.Password).To(Equal("s3cr3t"))
+			Expect(mockedScaler.calledWith.Spec.Triggers[0].Value).To(Equal(3))
+		})
+
+		It("should look consumers up through the referenced NatsConnection", func() {
+			By("Create the necessary resources")
+			jetStreamService := &mockNatsBackend{state: nats.ConsumerState{NumPending: 4}}
+			mockedScaler := &mockScaler{}
+
+			resourceName := fmt.Sprintf("test-resource-%d", GinkgoParallelProcess())
+			typeNamespacedName := types.NamespacedName{
+				Name:      resourceName,
+				Namespace: nsName,
+			}
+			spec := defaultNatsSpecs(depName, nsName, "")
+			spec.NatsConnectionRef = &scalingv1.NatsConnectionReference{Kind: scalingv1.ClusterNatsConnectionKind, Name: "shared-nats"}
+			createDummyScalingRuleSpec(typeNamespacedName, spec)
+			DeferCleanup(func() {
+				resource := &scalingv1.ScalingRule{}
+				err := k8sClient.Get(ctx, typeNamespacedName, resource)
+				Expect(err).NotTo(HaveOccurred())
+				By("Cleanup the specific resource instance ScalingRule")
+				deleteScalingRule(resource)
+			})
+
+			controllerReconciler := &ScalingRuleReconciler{
+				Client:           k8sClient,
+				Scheme:           k8sClient.Scheme(),
+				NatsService:      &mockNatsBackend{err: errors.New("monitoring backend must not be used")},
+				JetStreamService: jetStreamService,
+				Scaler:           mockedScaler,
+				Recorder:         recorder,
+			}
+
+			By("Reconciling before the connection exists")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			updated := &scalingv1.ScalingRule{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			cond := meta.FindStatusCondition(updated.Status.Conditions, scalingv1.ConditionNatsReachable)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Reason).To(Equal(reasonConnectionUnavailable))
+			Expect(recorder.Events).To(Receive(ContainSubstring(eventConnectionUnavailable)))
+
+			By("Reconciling once the connection exists")
+			conn := &scalingv1.ClusterNatsConnection{
+				ObjectMeta: metav1.ObjectMeta{Name: "shared-nats"},
+				Spec: scalingv1.NatsConnectionSpec{
+					Backend: scalingv1.NatsBackendJetStream,
+					URL:     "nats://shared-nats:4222",
+					Account: "ORDERS",
+				},
+			}
+			Expect(k8sClient.Create(ctx, conn)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, conn)).To(Succeed())
+			})
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(jetStreamService.calledWith.URL).To(Equal("nats://shared-nats:4222"))
+			Expect(jetStreamService.calledWith.Account).To(Equal("ORDERS"))
+			Expect(jetStreamService.calledWith.Auth).To(BeNil())
+			Expect(mockedScaler.calledWith.Spec.Triggers[0].Value).To(Equal(4))
+		})
+
+		It("should not scale a target also scaled by a HorizontalPodAutoscaler", func() {
+			By("Create the necessary resources")
+			backend := &mockNatsBackend{state: nats.ConsumerState{NumPending: 4}}
+			mockedScaler := &mockScaler{}
+
+			resourceName := fmt.Sprintf("test-resource-%d", GinkgoParallelProcess())
+			typeNamespacedName := types.NamespacedName{
+				Name:      resourceName,
+				Namespace: nsName,
+			}
+			spec := defaultNatsSpecs(depName, nsName, "http://nats:8222")
+			createDummyScalingRuleSpec(typeNamespacedName, spec)
+			DeferCleanup(func() {
+				resource := &scalingv1.ScalingRule{}
+				err := k8sClient.Get(ctx, typeNamespacedName, resource)
+				Expect(err).NotTo(HaveOccurred())
+				By("Cleanup the specific resource instance ScalingRule")
+				deleteScalingRule(resource)
+			})
+			hpa := &autoscalingv2.HorizontalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{Name: "orders-hpa", Namespace: nsName},
+				Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+					ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+						APIVersion: "apps/v1",
+						Kind:       "Deployment",
+						Name:       depName,
+					},
+					MaxReplicas: 5,
+				},
+			}
+			Expect(k8sClient.Create(ctx, hpa)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, hpa)).To(Succeed())
+			})
+
+			controllerReconciler := &ScalingRuleReconciler{
+				Client:      k8sClient,
+				Scheme:      k8sClient.Scheme(),
+				NatsService: backend,
+				Scaler:      mockedScaler,
+				Recorder:    recorder,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockedScaler.calledWith.Spec.Triggers).To(BeEmpty())
+
+			updated := &scalingv1.ScalingRule{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			cond := meta.FindStatusCondition(updated.Status.Conditions, scalingv1.ConditionConflict)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			Expect(cond.Reason).To(Equal(reasonHPAConflict))
+			Expect(cond.Message).To(ContainSubstring("HorizontalPodAutoscaler default/orders-hpa"))
+			Expect(meta.IsStatusConditionFalse(updated.Status.Conditions, scalingv1.ConditionReady)).To(BeTrue())
+			Expect(recorder.Events).To(Receive(ContainSubstring(eventConflict)))
+		})
+
+		It("should only let the oldest of the rules sharing a target scale it", func() {
+			By("Create the necessary resources")
+			backend := &mockNatsBackend{state: nats.ConsumerState{NumPending: 4}}
+			mockedScaler := &mockScaler{}
+
+			spec := defaultNatsSpecs(depName, nsName, "http://nats:8222")
+			older := types.NamespacedName{Name: fmt.Sprintf("test-resource-%d-a", GinkgoParallelProcess()), Namespace: nsName}
+			newer := types.NamespacedName{Name: fmt.Sprintf("test-resource-%d-b", GinkgoParallelProcess()), Namespace: nsName}
+			for _, tns := range []types.NamespacedName{older, newer} {
+				createDummyScalingRuleSpec(tns, spec)
+				DeferCleanup(func() {
+					resource := &scalingv1.ScalingRule{}
+					Expect(k8sClient.Get(ctx, tns, resource)).To(Succeed())
+					deleteScalingRule(resource)
+				})
+			}
+
+			controllerReconciler := &ScalingRuleReconciler{
+				Client:      k8sClient,
+				Scheme:      k8sClient.Scheme(),
+				NatsService: backend,
+				Scaler:      mockedScaler,
+				Recorder:    recorder,
+			}
+
+			By("Reconciling the newer rule")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: newer})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockedScaler.calledWith.Spec.Triggers).To(BeEmpty())
+			updated := &scalingv1.ScalingRule{}
+			Expect(k8sClient.Get(ctx, newer, updated)).To(Succeed())
+			cond := meta.FindStatusCondition(updated.Status.Conditions, scalingv1.ConditionConflict)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			Expect(cond.Reason).To(Equal(reasonRuleConflict))
+			Expect(cond.Message).To(ContainSubstring(older.String()))
+
+			By("Reconciling the older rule")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: older})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockedScaler.calledWith.Spec.Triggers).To(HaveLen(1))
+			Expect(k8sClient.Get(ctx, older, updated)).To(Succeed())
+			Expect(meta.IsStatusConditionFalse(updated.Status.Conditions, scalingv1.ConditionConflict)).To(BeTrue())
+		})
+
+		It("should restore the baseline replicas when the rule is deleted", func() {
+			By("Create the necessary resources")
+			backend := &mockNatsBackend{state: nats.ConsumerState{NumPending: 40}}
+			mockedScaler := &mockScaler{res: internalTypes.ScaleResult{
+				TargetUID:       "target-uid",
+				CurrentReplicas: 2,
+				DesiredReplicas: 2,
+			}}
+
+			resourceName := fmt.Sprintf("test-resource-%d", GinkgoParallelProcess())
+			typeNamespacedName := types.NamespacedName{
+				Name:      resourceName,
+				Namespace: nsName,
+			}
+			createDummyScalingRuleSpec(typeNamespacedName, defaultNatsSpecs(depName, nsName, "http://nats:8222"))
+
+			controllerReconciler := &ScalingRuleReconciler{
+				Client:      k8sClient,
+				Scheme:      k8sClient.Scheme(),
+				NatsService: backend,
+				Scaler:      mockedScaler,
+				Recorder:    recorder,
+			}
+
+			By("Reconciling the rule without scaling the target")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			updated := &scalingv1.ScalingRule{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Finalizers).To(ContainElement(restoreFinalizer))
+			Expect(updated.Status.BaselineReplicas).To(BeNil())
+
+			By("Reconciling the rule once it first scales the target")
+			mockedScaler.res.DesiredReplicas, mockedScaler.res.Scaled, mockedScaler.res.LastScaleTime = 3, true, time.Now()
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.BaselineReplicas).To(HaveValue(Equal(int32(2))))
+
+			By("Reconciling the rule once it scaled the target")
+			mockedScaler.res.CurrentReplicas = 3
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.BaselineReplicas).To(HaveValue(Equal(int32(2))))
+
+			By("Deleting the rule")
+			Expect(k8sClient.Delete(ctx, updated)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockedScaler.setWith.Target.Name).To(Equal(depName))
+			Expect(mockedScaler.setWith.Replicas).To(HaveValue(Equal(int32(2))))
+			err = k8sClient.Get(ctx, typeNamespacedName, updated)
+			Expect(k8serrs.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should keep polling but not scale a suspended rule", func() {
+			By("Create the necessary resources")
+			backend := &mockNatsBackend{state: nats.ConsumerState{NumPending: 40}}
+			mockedScaler := &mockScaler{res: internalTypes.ScaleResult{CurrentReplicas: 3, DesiredReplicas: 3}}
+
+			resourceName := fmt.Sprintf("test-resource-%d", GinkgoParallelProcess())
+			typeNamespacedName := types.NamespacedName{
+				Name:      resourceName,
+				Namespace: nsName,
+			}
+			spec := defaultNatsSpecs(depName, nsName, "http://nats:8222")
+			spec.Suspend = true
+			createDummyScalingRuleSpec(typeNamespacedName, spec)
+			DeferCleanup(func() {
+				resource := &scalingv1.ScalingRule{}
+				err := k8sClient.Get(ctx, typeNamespacedName, resource)
+				Expect(err).NotTo(HaveOccurred())
+				By("Cleanup the specific resource instance ScalingRule")
+				deleteScalingRule(resource)
+			})
+
+			controllerReconciler := &ScalingRuleReconciler{
+				Client:      k8sClient,
+				Scheme:      k8sClient.Scheme(),
+				NatsService: backend,
+				Scaler:      mockedScaler,
+				Recorder:    recorder,
+			}
+
+			By("Reconciling without pinned replicas")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backend.calledWith.Consumer).To(Equal("orders-consumer"))
+			Expect(mockedScaler.calledWith.Spec.Triggers).To(BeEmpty())
+			Expect(mockedScaler.setWith.Replicas).To(BeNil())
+			updated := &scalingv1.ScalingRule{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.LastObservedPending).To(Equal(40))
+			Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, scalingv1.ConditionSuspended)).To(BeTrue())
+			cond := meta.FindStatusCondition(updated.Status.Conditions, scalingv1.ConditionScalingActive)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(reasonSuspended))
+
+			By("Reconciling with pinned replicas")
+			pinned := int32(3)
+			updated.Spec.SuspendedReplicas = &pinned
+			Expect(k8sClient.Update(ctx, updated)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockedScaler.calledWith.Spec.Triggers).To(BeEmpty())
+			Expect(mockedScaler.setWith.Replicas).To(HaveValue(Equal(pinned)))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.DesiredReplicas).To(Equal(pinned))
+			Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, scalingv1.ConditionReady)).To(BeTrue())
+		})
+
+		It("should apply the overrides of the open schedule window", func() {
+			By("Create the necessary resources")
+			backend := &mockNatsBackend{state: nats.ConsumerState{NumPending: 4}}
+			mockedScaler := &mockScaler{}
+
+			resourceName := fmt.Sprintf("test-resource-%d", GinkgoParallelProcess())
+			typeNamespacedName := types.NamespacedName{
+				Name:      resourceName,
+				Namespace: nsName,
+			}
+			minReplicas, maxReplicas, upThreshold, downThreshold := int32(3), int32(6), 40, 4
+			spec := defaultNatsSpecs(depName, nsName, "http://nats:8222")
+			spec.Schedules = []scalingv1.ScheduleWindow{
+				{
+					// opens every minute for two minutes, so it is always open
+					Name:               "always",
+					Start:              "* * * * *",
+					DurationSeconds:    120,
+					TimeZone:           "Europe/Berlin",
+					MinReplicas:        &minReplicas,
+					MaxReplicas:        &maxReplicas,
+					ScaleUpThreshold:   &upThreshold,
+					ScaleDownThreshold: &downThreshold,
+				},
+			}
+			createDummyScalingRuleSpec(typeNamespacedName, spec)
+			DeferCleanup(func() {
+				resource := &scalingv1.ScalingRule{}
+				err := k8sClient.Get(ctx, typeNamespacedName, resource)
+				Expect(err).NotTo(HaveOccurred())
+				By("Cleanup the specific resource instance ScalingRule")
+				deleteScalingRule(resource)
+			})
+
+			controllerReconciler := &ScalingRuleReconciler{
+				Client:      k8sClient,
+				Scheme:      k8sClient.Scheme(),
+				NatsService: backend,
+				Scaler:      mockedScaler,
+				Recorder:    recorder,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockedScaler.calledWith.Spec.MinReplicas).To(Equal(minReplicas))
+			Expect(mockedScaler.calledWith.Spec.MaxReplicas).To(Equal(maxReplicas))
+			Expect(mockedScaler.calledWith.Spec.Triggers[0].ScaleUpThreshold).To(Equal(upThreshold))
+			Expect(mockedScaler.calledWith.Spec.Triggers[0].ScaleDownThreshold).To(Equal(downThreshold))
+
+			updated := &scalingv1.ScalingRule{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.ActiveSchedule).To(Equal("always"))
+			Expect(updated.Spec.MinReplicas).To(Equal(spec.MinReplicas))
+		})
+
+		It("should pass the prediction to the scaler", func() {
+			By("Create the necessary resources")
+			backend := &mockNatsBackend{state: nats.ConsumerState{NumPending: 150}}
+			mockedScaler := &mockScaler{res: internalTypes.ScaleResult{
+				CurrentReplicas: 2,
+				DesiredReplicas: 5,
+				Triggers:        []internalTypes.TriggerResult{{Name: "ORDERS/orders-consumer", Value: 150, Predicted: 450, DesiredReplicas: 5}},
+			}}
+
+			resourceName := fmt.Sprintf("test-resource-%d", GinkgoParallelProcess())
+			typeNamespacedName := types.NamespacedName{
+				Name:      resourceName,
+				Namespace: nsName,
+			}
+			spec := defaultNatsSpecs(depName, nsName, "http://nats:8222")
+			spec.Prediction = &scalingv1.Prediction{Model: scalingv1.PredictionModelExponential, Samples: 6, LookAheadSeconds: 90}
+			createDummyScalingRuleSpec(typeNamespacedName, spec)
+			DeferCleanup(func() {
+				resource := &scalingv1.ScalingRule{}
+				err := k8sClient.Get(ctx, typeNamespacedName, resource)
+				Expect(err).NotTo(HaveOccurred())
+				By("Cleanup the specific resource instance ScalingRule")
+				deleteScalingRule(resource)
+			})
+
+			controllerReconciler := &ScalingRuleReconciler{
+				Client:      k8sClient,
+				Scheme:      k8sClient.Scheme(),
+				NatsService: backend,
+				Scaler:      mockedScaler,
+				Recorder:    recorder,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockedScaler.calledWith.Spec.Prediction).To(Equal(&internalTypes.PredictionParams{
+				Model:     internalTypes.PredictionModelExponential,
+				Samples:   6,
+				LookAhead: 90 * time.Second,
+				// twice the 6 polls of 10 seconds
+				MaxAge:   2 * time.Minute,
+				Interval: 10 * time.Second,
+			}))
+
+			updated := &scalingv1.ScalingRule{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.Triggers).To(HaveLen(1))
+			Expect(updated.Status.Triggers[0].Predicted).To(Equal(450))
+		})
+
+		It("should scale on the ingress rate with the throughput policy", func() {
+			By("Create the necessary resources")
+			backend := &mockNatsBackend{state: nats.ConsumerState{
+				NumPending: 5000,
+				Rates:      nats.Rates{Ingress: 120.5, Ack: 80.25, Known: true},
+			}}
+			mockedScaler := &mockScaler{res: internalTypes.ScaleResult{
+				CurrentReplicas: 2,
+				DesiredReplicas: 3,
+				Triggers:        []internalTypes.TriggerResult{{Name: "ORDERS/orders-consumer", Value: 5000, DesiredReplicas: 3}},
+			}}
+
+			resourceName := fmt.Sprintf("test-resource-%d", GinkgoParallelProcess())
+			typeNamespacedName := types.NamespacedName{
+				Name:      resourceName,
+				Namespace: nsName,
+			}
+			spec := defaultNatsSpecs(depName, nsName, "http://nats:8222")
+			spec.ScalingPolicy = scalingv1.ScalingPolicyThroughput
+			rate := resource.MustParse("50")
+			spec.TargetRatePerReplica = &rate
+			createDummyScalingRuleSpec(typeNamespacedName, spec)
+			DeferCleanup(func() {
+				resource := &scalingv1.ScalingRule{}
+				err := k8sClient.Get(ctx, typeNamespacedName, resource)
+				Expect(err).NotTo(HaveOccurred())
+				By("Cleanup the specific resource instance ScalingRule")
+				deleteScalingRule(resource)
+			})
+
+			controllerReconciler := &ScalingRuleReconciler{
+				Client:      k8sClient,
+				Scheme:      k8sClient.Scheme(),
+				NatsService: backend,
+				Scaler:      mockedScaler,
+				Recorder:    recorder,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backend.calledWith.Rates).To(BeTrue())
+			Expect(mockedScaler.calledWith.Spec.Policy).To(Equal(internalTypes.ScalingPolicyThroughput))
+			Expect(mockedScaler.calledWith.Spec.Triggers).To(HaveLen(1))
+			trigger := mockedScaler.calledWith.Spec.Triggers[0]
+			Expect(trigger.IngressRate).To(Equal(120.5))
+			Expect(trigger.RateKnown).To(BeTrue())
+			Expect(trigger.TargetRatePerReplica).To(Equal(50.0))
+
+			updated := &scalingv1.ScalingRule{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.Triggers).To(HaveLen(1))
+			Expect(updated.Status.Triggers[0].IngressRate.String()).To(Equal("120500m"))
+			Expect(updated.Status.Triggers[0].AckRate.String()).To(Equal("80250m"))
+		})
+
+		It("should index rules by their scale target", func() {
+			rule := &scalingv1.ScalingRule{Spec: defaultNatsSpecs(depName, nsName, "http://nats:8222")}
+			Expect(indexByTarget(rule)).To(ConsistOf("apps/Deployment/default/test-deployment"))
+
+			rule.Spec.ScaleTargetRef = &scalingv1.ScaleTargetRef{
+				APIVersion: "argoproj.io/v1alpha1",
+				Kind:       "Rollout",
+				Name:       "orders",
+			}
+			Expect(indexByTarget(rule)).To(ConsistOf("argoproj.io/Rollout/default/orders"))
+		})
+
+		It("should map targets, HPAs and rules to the rules scaling the same target", func() {
+			newRule := func(name string, ref scalingv1.ScaleTargetRef) *scalingv1.ScalingRule {
+				spec := defaultNatsSpecs("", nsName, "http://nats:8222")
+				spec.ScaleTargetRef = &ref
+				return &scalingv1.ScalingRule{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: nsName}, Spec: spec}
+			}
+			deployment := scalingv1.ScaleTargetRef{APIVersion: "apps/v1", Kind: "Deployment", Name: depName}
+			ruleA, ruleB := newRule("rule-a", deployment), newRule("rule-b", deployment)
+			ruleC := newRule("rule-c", scalingv1.ScaleTargetRef{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "db"})
+			r := &ScalingRuleReconciler{Client: fake.NewClientBuilder().WithScheme(k8sClient.Scheme()).
+				WithIndex(&scalingv1.ScalingRule{}, targetIndexKey, indexByTarget).
+				WithObjects(ruleA, ruleB, ruleC).
+				Build()}
+			requests := func(names ...string) []reconcile.Request {
+				reqs := make([]reconcile.Request, 0, len(names))
+				for _, name := range names {
+					reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: nsName}})
+				}
+				return reqs
+			}
+			deployments := schema.GroupKind{Group: "apps", Kind: "Deployment"}
+			statefulSets := schema.GroupKind{Group: "apps", Kind: "StatefulSet"}
+
+			By("mapping a target to the rules scaling it")
+			dep := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: depName, Namespace: nsName}}
+			Expect(r.rulesForTarget(deployments)(ctx, dep)).To(ConsistOf(requests("rule-a", "rule-b")))
+			db := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: nsName}}
+			Expect(r.rulesForTarget(statefulSets)(ctx, db)).To(ConsistOf(requests("rule-c")))
+			// a workload of another kind with the same name isn't the target
+			Expect(r.rulesForTarget(deployments)(ctx, db)).To(BeEmpty())
+
+			By("mapping an HPA to the rules scaling its target")
+			hpa := &autoscalingv2.HorizontalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{Name: "orders-hpa", Namespace: nsName},
+				Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+					ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: depName},
+				},
+			}
+			Expect(r.rulesForHPA(ctx, hpa)).To(ConsistOf(requests("rule-a", "rule-b")))
+			hpa.Namespace = "other"
+			Expect(r.rulesForHPA(ctx, hpa)).To(BeEmpty())
+			hpa.Spec.ScaleTargetRef.APIVersion = "apps/v1/invalid"
+			Expect(r.rulesForHPA(ctx, hpa)).To(BeEmpty())
+
+			By("mapping a rule to the other rules scaling its target")
+			Expect(r.rulesSharingTarget(ctx, ruleA)).To(ConsistOf(requests("rule-b")))
+			Expect(r.rulesSharingTarget(ctx, ruleC)).To(BeEmpty())
+		})
+
+		It("should watch the kind of a scale target once, and retry kinds that fail to sync", func() {
+			statefulSet := appsv1.SchemeGroupVersion.WithKind("StatefulSet")
+			rollout := schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}
+			mapper := meta.NewDefaultRESTMapper(nil)
+			mapper.Add(statefulSet, meta.RESTScopeNamespace)
+			mapper.Add(rollout, meta.RESTScopeNamespace)
+			rule := &scalingv1.ScalingRule{
+				ObjectMeta: metav1.ObjectMeta{Name: "rule-db", Namespace: nsName},
+				Spec:       defaultNatsSpecs("", nsName, "http://nats:8222"),
+			}
+			rule.Spec.ScaleTargetRef = &scalingv1.ScaleTargetRef{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "db"}
+			ctrlr := &fakeController{queue: workqueue.NewTypedRateLimitingQueue(
+				workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())}
+			DeferCleanup(ctrlr.queue.ShutDown)
+			informers := newMetadataInformers()
+			r := &ScalingRuleReconciler{
+				Client: fake.NewClientBuilder().WithScheme(k8sClient.Scheme()).WithRESTMapper(mapper).
+					WithIndex(&scalingv1.ScalingRule{}, targetIndexKey, indexByTarget).
+					WithObjects(rule).
+					Build(),
+				controller:       ctrlr,
+				cache:            informers,
+				watchedTargets:   map[schema.GroupKind]struct{}{},
+				unwatchedTargets: map[schema.GroupKind]time.Time{},
+			}
+
+			By("watching a kind the first time a rule references it")
+			Expect(r.watchTarget(ctx, statefulSet)).To(Succeed())
+			Expect(r.watchTarget(ctx, statefulSet)).To(Succeed())
+			Expect(ctrlr.watches).To(Equal(1))
+			// the source registers its handler in the background, before it waits for the sync
+			Eventually(informers.syncCount).Should(Equal(1))
+			informers.informer(statefulSet).Add(&metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: nsName}})
+			Expect(ctrlr.queue.Len()).To(Equal(1))
+
+			By("dropping the watch of a kind that doesn't sync")
+			informers.setSynced(false)
+			Expect(r.watchTarget(ctx, rollout)).To(Succeed())
+			Expect(ctrlr.watches).To(Equal(2))
+			unwatched := func() bool {
+				r.watchMu.Lock()
+				defer r.watchMu.Unlock()
+				_, ok := r.unwatchedTargets[rollout.GroupKind()]
+				return ok
+			}
+			Eventually(unwatched).Should(BeTrue())
+			Expect(informers.informer(rollout)).To(BeNil())
+			Expect(r.watchedTargets).NotTo(HaveKey(rollout.GroupKind()))
+			// it isn't retried on every reconcile
+			Expect(r.watchTarget(ctx, rollout)).To(Succeed())
+			Expect(ctrlr.watches).To(Equal(2))
+
+			By("retrying it once the retry interval passed")
+			informers.setSynced(true)
+			r.watchMu.Lock()
+			r.unwatchedTargets[rollout.GroupKind()] = time.Now().Add(-time.Second)
+			r.watchMu.Unlock()
+			Expect(r.watchTarget(ctx, rollout)).To(Succeed())
+			Expect(ctrlr.watches).To(Equal(3))
+			Consistently(unwatched, 100*time.Millisecond).Should(BeFalse())
+			Expect(r.watchedTargets).To(HaveKey(rollout.GroupKind()))
+
+			By("failing on kinds unknown to the API server")
+			Expect(r.watchTarget(ctx, schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Unknown"})).
+				To(MatchError(ContainSubstring("failed to map")))
+		})
+
+		It("should not wait for the informer of a new target kind to sync", func() {
+			statefulSet := appsv1.SchemeGroupVersion.WithKind("StatefulSet")
+			mapper := meta.NewDefaultRESTMapper(nil)
+			mapper.Add(statefulSet, meta.RESTScopeNamespace)
+			resourceName := fmt.Sprintf("test-resource-%d", GinkgoParallelProcess())
+			rule := &scalingv1.ScalingRule{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: nsName},
+				Spec:       defaultNatsSpecs("", nsName, "http://nats:8222"),
+			}
+			rule.Spec.ScaleTargetRef = &scalingv1.ScaleTargetRef{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "db"}
+			ctrlr := &fakeController{queue: workqueue.NewTypedRateLimitingQueue(
+				workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())}
+			DeferCleanup(ctrlr.queue.ShutDown)
+			neverSyncing := newMetadataInformers()
+			neverSyncing.neverSync = true
+			r := &ScalingRuleReconciler{
+				Client: fake.NewClientBuilder().WithScheme(k8sClient.Scheme()).WithRESTMapper(mapper).
+					WithIndex(&scalingv1.ScalingRule{}, targetIndexKey, indexByTarget).
+					WithStatusSubresource(&scalingv1.ScalingRule{}).
+					WithObjects(rule).
+					Build(),
+				Scheme:           k8sClient.Scheme(),
+				NatsService:      &mockNatsBackend{state: nats.ConsumerState{NumPending: 1}},
+				Scaler:           &mockScaler{},
+				Recorder:         recorder,
+				controller:       ctrlr,
+				cache:            neverSyncing,
+				watchedTargets:   map[schema.GroupKind]struct{}{},
+				unwatchedTargets: map[schema.GroupKind]time.Time{},
+			}
+
+			start := time.Now()
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: resourceName, Namespace: nsName}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+			Expect(ctrlr.watches).To(Equal(1))
+		})
+	})
+})
+
+func defaultNatsSpecs(depName, ns, natsURL string) scalingv1.ScalingRuleSpec {
+	return scalingv1.ScalingRuleSpec{
+		DeploymentName:      depName,
+		Namespace:           ns,
+		MinReplicas:         1,
+		MaxReplicas:         3,
+		NatsMonitoringURL:   natsURL,
+		StreamName:          "ORDERS",
+		ConsumerName:        "orders-consumer",
+		ScaleUpThreshold:    10,
+		ScaleDownThreshold:  2,
+		PollIntervalSeconds: 10,
+	}
+}
+
+// deleteScalingRule deletes the rule without waiting for the reconciler to restore its target.
+func deleteScalingRule(rule *scalingv1.ScalingRule) {
+	rule.Finalizers = nil
+	Expect(k8sClient.Update(ctx, rule)).To(Succeed())
+	Expect(k8sClient.Delete(ctx, rule)).To(Succeed())
 }
 
 func createDummyScalingRuleSpec(tns types.NamespacedName, spec scalingv1.ScalingRuleSpec) {
@@ -22,21 +22,33 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 	"testing"
 
 	"github.com/Av1shay/nats-scaler/internal/nats"
 	internalTypes "github.com/Av1shay/nats-scaler/internal/types"
-	"k8s.io/apimachinery/pkg/types"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllertest"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	scalingv1 "github.com/Av1shay/nats-scaler/api/v1"
 	// +kubebuilder:scaffold:imports
@@ -86,9 +98,10 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 	Expect(cfg).NotTo(BeNil())
 
-	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+	c, err := client.New(cfg, client.Options{Scheme: scheme.Scheme})
 	Expect(err).NotTo(HaveOccurred())
-	Expect(k8sClient).NotTo(BeNil())
+	Expect(c).NotTo(BeNil())
+	k8sClient = indexedClient{Client: c, indexes: map[string]client.IndexerFunc{targetIndexKey: indexByTarget}}
 })
 
 var _ = AfterSuite(func() {
@@ -121,20 +134,177 @@ func getFirstFoundEnvTestBinaryDir() string {
 	return ""
 }
 
+// indexedClient serves List calls selecting on the field indexes of the reconcilers, which the API server
+// doesn't know, by filtering the listed objects in memory like the manager's cache does.
+type indexedClient struct {
+	client.Client
+	indexes map[string]client.IndexerFunc
+}
+
+func (c indexedClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	lo := (&client.ListOptions{}).ApplyOptions(opts)
+	if lo.FieldSelector == nil {
+		return c.Client.List(ctx, list, opts...)
+	}
+	reqs := lo.FieldSelector.Requirements()
+	if len(reqs) != 1 {
+		return c.Client.List(ctx, list, opts...)
+	}
+	index, ok := c.indexes[reqs[0].Field]
+	if !ok {
+		return c.Client.List(ctx, list, opts...)
+	}
+	unfiltered := *lo
+	unfiltered.FieldSelector = nil
+	if err := c.Client.List(ctx, list, &unfiltered); err != nil {
+		return err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	var matching []runtime.Object
+	for _, item := range items {
+		if slices.Contains(index(item.(client.Object)), reqs[0].Value) {
+			matching = append(matching, item)
+		}
+	}
+	return meta.SetList(list, matching)
+}
+
+// fakeController starts the sources it is asked to watch right away, like a running controller.
+type fakeController struct {
+	controller.Controller
+	queue   workqueue.TypedRateLimitingInterface[reconcile.Request]
+	watches int
+}
+
+func (f *fakeController) Watch(src source.Source) error {
+	f.watches++
+	return src.Start(context.Background(), f.queue)
+}
+
+// metadataInformers serves fake informers for metadata-only objects, looked up by the kind they carry
+// rather than through the scheme, which doesn't know custom workloads. Sources get their informers in
+// the background, mu guards InformersByGVK and syncs.
+type metadataInformers struct {
+	*informertest.FakeInformers
+	mu *sync.Mutex
+	// syncs counts the waits for the informers to sync, sources wait once they registered their handler.
+	// The informers that never sync aren't counted.
+	syncs *int
+	// neverSync makes the informers wait until they are stopped, like those of a kind that can't be listed
+	neverSync bool
+}
+
+func newMetadataInformers() metadataInformers {
+	return metadataInformers{
+		FakeInformers: &informertest.FakeInformers{InformersByGVK: map[schema.GroupVersionKind]toolscache.SharedIndexInformer{}},
+		mu:            &sync.Mutex{},
+		syncs:         new(int),
+	}
+}
+
+func (c metadataInformers) GetInformer(_ context.Context, obj client.Object, _ ...cache.InformerGetOption) (cache.Informer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if _, ok := c.InformersByGVK[gvk]; !ok {
+		c.InformersByGVK[gvk] = &controllertest.FakeInformer{}
+	}
+	return c.InformersByGVK[gvk], nil
+}
+
+func (c metadataInformers) RemoveInformer(_ context.Context, obj client.Object) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.InformersByGVK, obj.GetObjectKind().GroupVersionKind())
+	return nil
+}
+
+func (c metadataInformers) WaitForCacheSync(ctx context.Context) bool {
+	if c.neverSync {
+		<-ctx.Done()
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.syncs++
+	return c.FakeInformers.WaitForCacheSync(ctx)
+}
+
+// setSynced sets whether the informers sync.
+func (c metadataInformers) setSynced(synced bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Synced = &synced
+}
+
+func (c metadataInformers) syncCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return *c.syncs
+}
+
+// informer returns the informer of the kind, nil if there is none.
+func (c metadataInformers) informer(gvk schema.GroupVersionKind) *controllertest.FakeInformer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	informer, _ := c.InformersByGVK[gvk].(*controllertest.FakeInformer)
+	return informer
+}
+
 type mockScaler struct {
 	calledWith struct {
-		Nn      types.NamespacedName
-		Spec    internalTypes.ScalerParams
-		Pending int
+		Target internalTypes.ScaleTarget
+		Spec   internalTypes.ScalerParams
+	}
+	setWith struct {
+		Target   internalTypes.ScaleTarget
+		Replicas *int32
 	}
+	res internalTypes.ScaleResult
 	err error
 }
 
-func (m *mockScaler) ReconcileScale(_ context.Context, _ client.Client, nn types.NamespacedName, spec internalTypes.ScalerParams, pendings int) error {
-	m.calledWith.Nn = nn
+func (m *mockScaler) ReconcileScale(_ context.Context, _ client.Client, target internalTypes.ScaleTarget, spec internalTypes.ScalerParams) (internalTypes.ScaleResult, error) {
+	m.calledWith.Target = target
 	m.calledWith.Spec = spec
-	m.calledWith.Pending = pendings
-	return m.err
+	return m.res, m.err
+}
+
+func (m *mockScaler) SetScale(_ context.Context, _ client.Client, target internalTypes.ScaleTarget, replicas int32, _ string) (internalTypes.ScaleResult, error) {
+	m.setWith.Target = target
+	m.setWith.Replicas = &replicas
+	return m.res, m.err
+}
+
+type mockNatsBackend struct {
+	calledWith nats.ConsumerQuery
+	state      nats.ConsumerState
+	// states overrides state per consumer name
+	states map[string]nats.ConsumerState
+	err    error
+}
+
+func (m *mockNatsBackend) GetConsumerState(_ context.Context, q nats.ConsumerQuery) (nats.ConsumerState, error) {
+	m.calledWith = q
+	if state, ok := m.states[q.Consumer]; ok {
+		return state, m.err
+	}
+	return m.state, m.err
+}
+
+type mockProber struct {
+	calledWith string
+	auth       *nats.Auth
+	version    string
+	err        error
+}
+
+func (m *mockProber) ServerVersion(_ context.Context, url string, auth *nats.Auth) (string, error) {
+	m.calledWith, m.auth = url, auth
+	return m.version, m.err
 }
 
 type mockNatsServer struct {
@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Av1shay/nats-scaler/pkg/errs"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	metricsNamespace = "nats_scaler"
+
+	// DirectionUp and DirectionDown label scale events.
+	DirectionUp   = "up"
+	DirectionDown = "down"
+
+	// codeNone labels NATS request errors that didn't come with an HTTP status, e.g. connection errors.
+	codeNone = "none"
+)
+
+var ruleLabels = []string{"namespace", "scalingrule"}
+
+var (
+	pendingMessages = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "pending_messages",
+		Help:      "Number of pending messages of the consumer seen on the last NATS lookup of a ScalingRule.",
+	}, ruleLabels)
+
+	currentReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "current_replicas",
+		Help:      "Replica count of the ScalingRule target as observed by the scaler.",
+	}, ruleLabels)
+
+	desiredReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "desired_replicas",
+		Help:      "Replica count the scaler computed for the ScalingRule target.",
+	}, ruleLabels)
+
+	scaleEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "scale_events_total",
+		Help:      "Number of times the ScalingRule target was scaled, by direction.",
+	}, append(ruleLabels, "direction"))
+
+	cooldownSkips = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cooldown_skips_total",
+		Help:      "Number of scale recommendations skipped because the cooldown was in effect.",
+	}, ruleLabels)
+
+	natsRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "nats_request_duration_seconds",
+		Help:      "Latency of NATS consumer lookups, by backend.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"backend"})
+
+	natsRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "nats_request_errors_total",
+		Help:      "Number of failed NATS consumer lookups, by backend and HTTP status code (none when there was no response).",
+	}, []string{"backend", "code"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		pendingMessages,
+		currentReplicas,
+		desiredReplicas,
+		scaleEvents,
+		cooldownSkips,
+		natsRequestDuration,
+		natsRequestErrors,
+	)
+}
+
+// ObserveNatsRequest records the latency of a NATS consumer lookup and, when it failed, an error
+// labeled with the HTTP status code of errs.HTTPStatusCodeErr.
+func ObserveNatsRequest(backend string, duration time.Duration, err error) {
+	natsRequestDuration.WithLabelValues(backend).Observe(duration.Seconds())
+	if err == nil {
+		return
+	}
+	code := codeNone
+	var scErr *errs.HTTPStatusCodeErr
+	if errors.As(err, &scErr) {
+		code = strconv.Itoa(scErr.Code)
+	}
+	natsRequestErrors.WithLabelValues(backend, code).Inc()
+}
+
+// RecordPending records the pending messages seen for a ScalingRule.
+func RecordPending(namespace, name string, pending int) {
+	pendingMessages.WithLabelValues(namespace, name).Set(float64(pending))
+}
+
+// RecordReplicas records the current and desired replicas of a ScalingRule target.
+func RecordReplicas(namespace, name string, current, desired int32) {
+	currentReplicas.WithLabelValues(namespace, name).Set(float64(current))
+	desiredReplicas.WithLabelValues(namespace, name).Set(float64(desired))
+}
+
+// RecordScale counts a scale of a ScalingRule target in the given direction.
+func RecordScale(namespace, name, direction string) {
+	scaleEvents.WithLabelValues(namespace, name, direction).Inc()
+}
+
+// RecordCooldownSkip counts a scale recommendation of a ScalingRule skipped by the cooldown.
+func RecordCooldownSkip(namespace, name string) {
+	cooldownSkips.WithLabelValues(namespace, name).Inc()
+}
+
+// DeleteRule drops all series of a deleted ScalingRule.
+func DeleteRule(namespace, name string) {
+	labels := prometheus.Labels{"namespace": namespace, "scalingrule": name}
+	pendingMessages.DeletePartialMatch(labels)
+	currentReplicas.DeletePartialMatch(labels)
+	desiredReplicas.DeletePartialMatch(labels)
+	scaleEvents.DeletePartialMatch(labels)
+	cooldownSkips.DeletePartialMatch(labels)
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Av1shay/nats-scaler/pkg/errs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserveNatsRequest(t *testing.T) {
+	ObserveNatsRequest("monitoring", 10*time.Millisecond, nil)
+	ObserveNatsRequest("monitoring", 10*time.Millisecond, &errs.HTTPStatusCodeErr{Code: 503})
+	ObserveNatsRequest("monitoring", 10*time.Millisecond, errors.New("connection refused"))
+
+	require.Equal(t, 1, testutil.CollectAndCount(natsRequestDuration))
+	require.Equal(t, float64(1), testutil.ToFloat64(natsRequestErrors.WithLabelValues("monitoring", "503")))
+	require.Equal(t, float64(1), testutil.ToFloat64(natsRequestErrors.WithLabelValues("monitoring", codeNone)))
+}
+
+func TestDeleteRule(t *testing.T) {
+	RecordPending("default", "orders", 42)
+	RecordReplicas("default", "orders", 2, 3)
+	RecordScale("default", "orders", DirectionUp)
+	RecordCooldownSkip("default", "orders")
+	RecordPending("default", "payments", 7)
+
+	require.Equal(t, float64(42), testutil.ToFloat64(pendingMessages.WithLabelValues("default", "orders")))
+	require.Equal(t, float64(3), testutil.ToFloat64(desiredReplicas.WithLabelValues("default", "orders")))
+	require.Equal(t, float64(1), testutil.ToFloat64(scaleEvents.WithLabelValues("default", "orders", DirectionUp)))
+
+	DeleteRule("default", "orders")
+	require.Equal(t, 1, testutil.CollectAndCount(pendingMessages))
+	require.Equal(t, 0, testutil.CollectAndCount(scaleEvents))
+	require.Equal(t, 0, testutil.CollectAndCount(cooldownSkips))
+}
@@ -0,0 +1,111 @@
+package nats
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	natsgo "github.com/nats-io/nats.go"
+)
+
+// Auth holds the credentials used to reach a NATS endpoint, as read from the Secret referenced by a rule.
+// All fields are optional.
+type Auth struct {
+	// CACert is a PEM bundle used to verify the server certificate instead of the system roots.
+	CACert []byte
+	// ClientCert and ClientKey are a PEM client certificate and key, for mutual TLS.
+	ClientCert []byte
+	ClientKey  []byte
+	// Token is sent as a bearer token to the monitoring endpoint and as the auth token over NATS.
+	Token string
+	// Username and Password are sent as basic auth to the monitoring endpoint and as user credentials over NATS.
+	Username string
+	Password string
+}
+
+// idleClientTimeout is how long a connection or client of an endpoint is kept unused before it is closed,
+// e.g. once the credentials it was made with rotated or the rules using it were deleted.
+const idleClientTimeout = 10 * time.Minute
+
+// clientKey identifies the connections and clients made to an endpoint with the given credentials. Rules
+// reaching the same endpoint with other credentials get their own, rather than replacing each other's.
+type clientKey struct {
+	url         string
+	fingerprint string
+}
+
+// fingerprint identifies the credentials, so that clients built from them aren't shared with other credentials.
+func (a *Auth) fingerprint() string {
+	if a == nil {
+		return ""
+	}
+	h := sha256.New()
+	for _, b := range [][]byte{a.CACert, a.ClientCert, a.ClientKey, []byte(a.Token), []byte(a.Username), []byte(a.Password)} {
+		// length prefixed, so that moving bytes between fields changes the fingerprint
+		_, _ = fmt.Fprintf(h, "%d:", len(b))
+		_, _ = h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// tlsConfig returns the TLS configuration of the credentials, nil when they hold no TLS material.
+func (a *Auth) tlsConfig() (*tls.Config, error) {
+	if a == nil || (len(a.CACert) == 0 && len(a.ClientCert) == 0 && len(a.ClientKey) == 0) {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(a.CACert) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(a.CACert) {
+			return nil, errors.New("failed to parse the CA certificate")
+		}
+		cfg.RootCAs = pool
+	}
+	if len(a.ClientCert) > 0 || len(a.ClientKey) > 0 {
+		cert, err := tls.X509KeyPair(a.ClientCert, a.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// authorize sets the Authorization header of a monitoring endpoint request.
+func (a *Auth) authorize(req *http.Request) {
+	switch {
+	case a == nil:
+	case a.Token != "":
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	case a.Username != "":
+		req.SetBasicAuth(a.Username, a.Password)
+	}
+}
+
+// natsOptions returns the connection options of the credentials.
+func (a *Auth) natsOptions() ([]natsgo.Option, error) {
+	if a == nil {
+		return nil, nil
+	}
+	var opts []natsgo.Option
+	switch {
+	case a.Token != "":
+		opts = append(opts, natsgo.Token(a.Token))
+	case a.Username != "":
+		opts = append(opts, natsgo.UserInfo(a.Username, a.Password))
+	}
+	tlsConfig, err := a.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		opts = append(opts, natsgo.Secure(tlsConfig))
+	}
+	return opts, nil
+}
@@ -0,0 +1,87 @@
+package nats
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	minEndpointBackoff = 5 * time.Second
+	maxEndpointBackoff = 5 * time.Minute
+)
+
+// endpointHealth remembers the monitoring endpoints that failed. A failing endpoint is tried after the
+// healthy ones until its backoff expires, the backoff doubling with every consecutive failure.
+type endpointHealth struct {
+	mu       sync.Mutex
+	failures map[string]endpointFailure
+	// next is the round-robin position per set of endpoints
+	next map[string]int
+	now  func() time.Time
+}
+
+type endpointFailure struct {
+	count   int
+	retryAt time.Time
+}
+
+func newEndpointHealth() *endpointHealth {
+	return &endpointHealth{
+		failures: make(map[string]endpointFailure),
+		next:     make(map[string]int),
+		now:      time.Now,
+	}
+}
+
+// order returns the endpoints in the order they should be tried: the healthy ones first, starting at the
+// next one in turn with roundRobin, then the failing ones by the time their backoff expires.
+func (h *endpointHealth) order(urls []string, roundRobin bool) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rotated := urls
+	if roundRobin && len(urls) > 1 {
+		key := strings.Join(urls, ",")
+		start := h.next[key] % len(urls)
+		h.next[key] = start + 1
+		rotated = append(append(make([]string, 0, len(urls)), urls[start:]...), urls[:start]...)
+	}
+
+	now := h.now()
+	healthy := make([]string, 0, len(rotated))
+	var failing []string
+	for _, u := range rotated {
+		if f, ok := h.failures[u]; ok && now.Before(f.retryAt) {
+			failing = append(failing, u)
+			continue
+		}
+		healthy = append(healthy, u)
+	}
+	// insertion sort keeps the list order among endpoints retrying at the same time
+	for i := 1; i < len(failing); i++ {
+		for j := i; j > 0 && h.failures[failing[j]].retryAt.Before(h.failures[failing[j-1]].retryAt); j-- {
+			failing[j], failing[j-1] = failing[j-1], failing[j]
+		}
+	}
+	return append(healthy, failing...)
+}
+
+func (h *endpointHealth) success(url string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.failures, url)
+}
+
+func (h *endpointHealth) failure(url string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f := h.failures[url]
+	f.count++
+	backoff := maxEndpointBackoff
+	if f.count <= 6 { // 5s << 6 is past the max already
+		backoff = min(minEndpointBackoff<<(f.count-1), maxEndpointBackoff)
+	}
+	f.retryAt = h.now().Add(backoff)
+	h.failures[url] = f
+}
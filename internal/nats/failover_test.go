@@ -0,0 +1,44 @@
+package nats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointHealth(t *testing.T) {
+	now := time.Now()
+	h := newEndpointHealth()
+	h.now = func() time.Time { return now }
+	urls := []string{"http://a", "http://b", "http://c"}
+
+	require.Equal(t, urls, h.order(urls, false))
+	require.Equal(t, urls, h.order(urls, false))
+
+	// round robin starts at the next endpoint on every lookup
+	require.Equal(t, []string{"http://a", "http://b", "http://c"}, h.order(urls, true))
+	require.Equal(t, []string{"http://b", "http://c", "http://a"}, h.order(urls, true))
+	require.Equal(t, []string{"http://c", "http://a", "http://b"}, h.order(urls, true))
+	require.Equal(t, []string{"http://a", "http://b", "http://c"}, h.order(urls, true))
+
+	// failing endpoints go last, the one retrying first ahead of the others
+	h.failure("http://a")
+	h.failure("http://a")
+	h.failure("http://b")
+	require.Equal(t, []string{"http://c", "http://b", "http://a"}, h.order(urls, false))
+
+	// the backoff doubles with every failure
+	now = now.Add(minEndpointBackoff)
+	require.Equal(t, []string{"http://b", "http://c", "http://a"}, h.order(urls, false))
+	now = now.Add(minEndpointBackoff)
+	require.Equal(t, urls, h.order(urls, false))
+
+	for range 20 {
+		h.failure("http://c")
+	}
+	require.Equal(t, now.Add(maxEndpointBackoff), h.failures["http://c"].retryAt)
+
+	h.success("http://c")
+	require.Equal(t, urls, h.order(urls, false))
+}
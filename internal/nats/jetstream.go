@@ -0,0 +1,164 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Av1shay/nats-scaler/internal/metrics"
+	"github.com/Av1shay/nats-scaler/pkg/errs"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// JetStreamService looks up consumer state through the JetStream API over the NATS protocol
+// ($JS.API.CONSUMER.INFO.<stream>.<consumer>), so it works against clusters where the HTTP
+// monitoring port is not reachable from the operator.
+type JetStreamService struct {
+	opts []natsgo.Option
+
+	mu    sync.Mutex
+	conns map[clientKey]*jsConn
+	rates *rateTracker
+}
+
+// jsConn is an open connection and the last time it was used.
+type jsConn struct {
+	nc       *natsgo.Conn
+	lastUsed time.Time
+}
+
+func NewJetStreamService(opts ...natsgo.Option) *JetStreamService {
+	return &JetStreamService{
+		opts:  append([]natsgo.Option{natsgo.Name("nats-scaler")}, opts...),
+		conns: make(map[clientKey]*jsConn),
+		rates: newRateTracker(),
+	}
+}
+
+func (s *JetStreamService) GetConsumerState(ctx context.Context, q ConsumerQuery) (ConsumerState, error) {
+	start := time.Now()
+	state, err := s.getConsumerState(ctx, q)
+	metrics.ObserveNatsRequest(backendJetStream, time.Since(start), err)
+	return state, err
+}
+
+func (s *JetStreamService) getConsumerState(ctx context.Context, q ConsumerQuery) (ConsumerState, error) {
+	nc, err := s.conn(q.URL, q.Auth)
+	if err != nil {
+		return ConsumerState{}, err
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return ConsumerState{}, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+
+	consumer, err := js.Consumer(ctx, q.Stream, q.Consumer)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrConsumerNotFound) || errors.Is(err, jetstream.ErrStreamNotFound) {
+			return ConsumerState{}, &errs.ConsumerNotFoundErr{Stream: q.Stream, Consumer: q.Consumer, Err: err}
+		}
+		return ConsumerState{}, fmt.Errorf("failed to get JetStream consumer info: %w", err)
+	}
+
+	info := consumer.CachedInfo()
+	state := ConsumerState{
+		NumPending:     int(info.NumPending),
+		NumAckPending:  info.NumAckPending,
+		NumRedelivered: info.NumRedelivered,
+		NumWaiting:     info.NumWaiting,
+	}
+	if q.Rates {
+		stream, err := js.Stream(ctx, q.Stream)
+		if err != nil {
+			if errors.Is(err, jetstream.ErrStreamNotFound) {
+				return ConsumerState{}, &errs.ConsumerNotFoundErr{Stream: q.Stream, Consumer: q.Consumer, Err: err}
+			}
+			return ConsumerState{}, fmt.Errorf("failed to get JetStream stream info: %w", err)
+		}
+		// the account is determined by the credentials
+		key := rateKey{endpoint: q.URL, account: q.Auth.fingerprint(), stream: q.Stream, consumer: q.Consumer}
+		state.Rates = s.rates.observe(key, stream.CachedInfo().State.LastSeq, info.AckFloor.Stream, time.Now())
+	}
+	return state, nil
+}
+
+// ServerVersion returns the version of the server the connection to natsURL is established with.
+func (s *JetStreamService) ServerVersion(ctx context.Context, natsURL string, auth *Auth) (version string, err error) {
+	defer func(start time.Time) {
+		metrics.ObserveNatsRequest(backendJetStream, time.Since(start), err)
+	}(time.Now())
+
+	nc, err := s.conn(natsURL, auth)
+	if err != nil {
+		return "", err
+	}
+	// a cached connection may have lost the server since, make sure it is still answering
+	if err := nc.FlushWithContext(ctx); err != nil {
+		return "", fmt.Errorf("failed to reach NATS at %s: %w", natsURL, err)
+	}
+	return nc.ConnectedServerVersion(), nil
+}
+
+// conn returns a connection to natsURL made with the credentials, reusing an open one when possible.
+// Connections left unused, e.g. after the credentials rotated, are closed. The connection is dialed
+// without holding the lock, so that a slow server doesn't hold up the lookups of other rules.
+func (s *JetStreamService) conn(natsURL string, auth *Auth) (*natsgo.Conn, error) {
+	key := clientKey{url: natsURL, fingerprint: auth.fingerprint()}
+	if nc := s.cachedConn(key); nc != nil {
+		return nc, nil
+	}
+
+	opts, err := auth.natsOptions()
+	if err != nil {
+		return nil, err
+	}
+	nc, err := natsgo.Connect(natsURL, append(append([]natsgo.Option{}, s.opts...), opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS at %s: %w", natsURL, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.conns[key]; ok && !c.nc.IsClosed() {
+		// another lookup connected in the meantime
+		nc.Close()
+		c.lastUsed = time.Now()
+		return c.nc, nil
+	}
+	s.conns[key] = &jsConn{nc: nc, lastUsed: time.Now()}
+	return nc, nil
+}
+
+// cachedConn returns the open connection of the key, nil if there is none. Idle connections are closed.
+func (s *JetStreamService) cachedConn(key clientKey) *natsgo.Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, c := range s.conns {
+		if c.nc.IsClosed() || now.Sub(c.lastUsed) > idleClientTimeout {
+			c.nc.Close()
+			delete(s.conns, k)
+		}
+	}
+	c, ok := s.conns[key]
+	if !ok {
+		return nil
+	}
+	c.lastUsed = now
+	return c.nc
+}
+
+// Close closes all cached NATS connections.
+func (s *JetStreamService) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, c := range s.conns {
+		c.nc.Close()
+		delete(s.conns, k)
+	}
+}
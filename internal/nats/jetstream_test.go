@@ -0,0 +1,157 @@
+package nats
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Av1shay/nats-scaler/pkg/errs"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/require"
+)
+
+// mockJetStreamServer speaks just enough of the NATS client protocol to answer
+// JetStream API requests with canned responses.
+type mockJetStreamServer struct {
+	t         *testing.T
+	listener  net.Listener
+	mu        sync.Mutex
+	responses map[string]string // subject -> JSON response
+	requested []string
+}
+
+func newMockJetStreamServer(t *testing.T, responses map[string]string) *mockJetStreamServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	m := &mockJetStreamServer{t: t, listener: l, responses: responses}
+	go m.serve()
+	t.Cleanup(func() { _ = l.Close() })
+	return m
+}
+
+func (m *mockJetStreamServer) URL() string {
+	return "nats://" + m.listener.Addr().String()
+}
+
+func (m *mockJetStreamServer) serve() {
+	for {
+		conn, err := m.listener.Accept()
+		if err != nil {
+			return
+		}
+		go m.handle(conn)
+	}
+}
+
+func (m *mockJetStreamServer) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	_, _ = fmt.Fprintf(conn, "INFO {\"server_id\":\"mock\",\"version\":\"2.10.0\",\"headers\":true,\"max_payload\":1048576}\r\n")
+
+	subs := map[string]string{} // subject pattern -> sid
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "PING":
+			_, _ = fmt.Fprint(conn, "PONG\r\n")
+		case "SUB":
+			subs[fields[1]] = fields[len(fields)-1]
+		case "PUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			subject, reply := fields[1], fields[2]
+			m.mu.Lock()
+			m.requested = append(m.requested, subject)
+			resp, ok := m.responses[subject]
+			m.mu.Unlock()
+			if !ok {
+				resp = `{"error":{"code":503,"description":"no responders"}}`
+			}
+			prefix := reply[:strings.LastIndex(reply, ".")+1]
+			sid := subs[prefix+"*"]
+			_, _ = fmt.Fprintf(conn, "MSG %s %s %d\r\n%s\r\n", reply, sid, len(resp), resp)
+		}
+	}
+}
+
+func TestJetStreamService_GetConsumerState(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	server := newMockJetStreamServer(t, map[string]string{
+		"$JS.API.CONSUMER.INFO.EVENTS.xxx": `{"type":"io.nats.jetstream.api.v1.consumer_info_response",` +
+			`"stream_name":"EVENTS","name":"xxx","config":{"durable_name":"xxx"},"num_pending":250,"num_ack_pending":3,` +
+			`"ack_floor":{"consumer_seq":850,"stream_seq":900}}`,
+		"$JS.API.STREAM.INFO.EVENTS": `{"type":"io.nats.jetstream.api.v1.stream_info_response",` +
+			`"config":{"name":"EVENTS"},"state":{"last_seq":1000}}`,
+		"$JS.API.CONSUMER.INFO.EVENTS.missing": `{"type":"io.nats.jetstream.api.v1.consumer_info_response",` +
+			`"error":{"code":404,"err_code":10014,"description":"consumer not found"}}`,
+	})
+
+	s := NewJetStreamService()
+	t.Cleanup(s.Close)
+
+	res, err := s.GetConsumerState(ctx, ConsumerQuery{URL: server.URL(), Stream: "EVENTS", Consumer: "xxx"})
+	require.NoError(t, err)
+	require.Equal(t, ConsumerState{NumPending: 250, NumAckPending: 3}, res)
+
+	_, err = s.GetConsumerState(ctx, ConsumerQuery{URL: server.URL(), Stream: "EVENTS", Consumer: "missing"})
+	require.ErrorIs(t, err, jetstream.ErrConsumerNotFound)
+	var consumerErr *errs.ConsumerNotFoundErr
+	require.ErrorAs(t, err, &consumerErr)
+	require.ErrorContains(t, err, "couldn't find NATS stream <EVENTS>, consumer <missing>")
+
+	// the connection is reused across lookups
+	require.Len(t, s.conns, 1)
+	require.Equal(t, []string{"$JS.API.CONSUMER.INFO.EVENTS.xxx", "$JS.API.CONSUMER.INFO.EVENTS.missing"}, server.requested)
+
+	version, err := s.ServerVersion(ctx, server.URL(), nil)
+	require.NoError(t, err)
+	require.Equal(t, "2.10.0", version)
+	require.Len(t, s.conns, 1)
+
+	// other credentials get their own connection, without closing the one in use
+	anonymous := s.conns[clientKey{url: server.URL()}].nc
+	_, err = s.ServerVersion(ctx, server.URL(), &Auth{Token: "t0ken"})
+	require.NoError(t, err)
+	require.Len(t, s.conns, 2)
+	require.False(t, anonymous.IsClosed())
+
+	// connections left unused are closed
+	s.conns[clientKey{url: server.URL()}].lastUsed = time.Now().Add(-idleClientTimeout - time.Second)
+	_, err = s.ServerVersion(ctx, server.URL(), &Auth{Token: "t0ken"})
+	require.NoError(t, err)
+	require.Len(t, s.conns, 1)
+	require.True(t, anonymous.IsClosed())
+
+	// rates take the stream info too, the first lookup has nothing to measure against
+	res, err = s.GetConsumerState(ctx, ConsumerQuery{URL: server.URL(), Stream: "EVENTS", Consumer: "xxx", Rates: true})
+	require.NoError(t, err)
+	require.Equal(t, Rates{}, res.Rates)
+	require.Equal(t, "$JS.API.STREAM.INFO.EVENTS", server.requested[len(server.requested)-1])
+	require.Len(t, s.rates.readings, 1)
+	for _, reading := range s.rates.readings {
+		require.Equal(t, uint64(1000), reading.lastSeq)
+		require.Equal(t, uint64(900), reading.ackFloor)
+	}
+
+	_, err = s.GetConsumerState(ctx, ConsumerQuery{URL: "nats://127.0.0.1:1", Stream: "EVENTS", Consumer: "xxx"})
+	require.ErrorContains(t, err, "failed to connect to NATS")
+}
@@ -5,15 +5,37 @@ type JszResponse struct {
 }
 
 type AccountDetails struct {
+	Name         string         `json:"name"`
+	ID           string         `json:"id"`
 	StreamDetail []StreamDetail `json:"stream_detail"`
 }
 
 type StreamDetail struct {
 	Name           string           `json:"name"`
+	State          StreamState      `json:"state"`
 	ConsumerDetail []ConsumerDetail `json:"consumer_detail"`
 }
 
+type StreamState struct {
+	LastSeq uint64 `json:"last_seq"`
+}
+
 type ConsumerDetail struct {
-	Name       string `json:"name"`
-	NumPending int    `json:"num_pending"`
+	Name           string `json:"name"`
+	NumPending     int    `json:"num_pending"`
+	NumAckPending  int    `json:"num_ack_pending"`
+	NumRedelivered int    `json:"num_redelivered"`
+	NumWaiting     int    `json:"num_waiting"`
+	// AckFloor is the last message acknowledged along with all the messages before it.
+	AckFloor SequenceInfo `json:"ack_floor"`
+}
+
+type SequenceInfo struct {
+	ConsumerSeq uint64 `json:"consumer_seq"`
+	StreamSeq   uint64 `json:"stream_seq"`
+}
+
+type VarzResponse struct {
+	ServerID string `json:"server_id"`
+	Version  string `json:"version"`
 }
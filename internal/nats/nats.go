@@ -9,47 +9,320 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/Av1shay/nats-scaler/internal/metrics"
 	"github.com/Av1shay/nats-scaler/pkg/errs"
 
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 const (
-	GlobalAccountName = "$G" // we'll use only the global account for the sake of the demo
+	GlobalAccountName = "$G" // used when no account is configured
+
+	defaultCacheTTL = 5 * time.Second
+
+	// backend labels of the NATS request metrics
+	backendMonitoring = "monitoring"
+	backendJetStream  = "jetstream"
 )
 
-var ErrNoAccountFound = errors.New("no accounts found")
+// ConsumerQuery identifies the JetStream consumer to look up.
+type ConsumerQuery struct {
+	URL string
+	// FallbackURLs are more monitoring endpoints of the same cluster, tried when URL fails.
+	// Only used by the monitoring backend.
+	FallbackURLs []string
+	// RoundRobin starts each lookup at the next of URL and FallbackURLs instead of at URL.
+	RoundRobin bool
+	// Account is only used by the monitoring backend, defaults to GlobalAccountName.
+	// Over the NATS protocol the account is determined by the connection credentials.
+	Account  string
+	Stream   string
+	Consumer string
+	// Auth holds the credentials of the endpoint, nil when it needs none.
+	Auth *Auth
+	// Rates requests ConsumerState.Rates, measured between this lookup and the previous one of the
+	// consumer. Over the NATS protocol it takes an extra request for the stream info.
+	Rates bool
+}
+
+// ConsumerState is the part of the JetStream consumer info the scaler acts on.
+type ConsumerState struct {
+	// NumPending is the number of messages not yet delivered to the consumer.
+	NumPending int
+	// NumAckPending is the number of messages delivered but not yet acknowledged.
+	NumAckPending int
+	// NumRedelivered is the number of messages that were redelivered.
+	NumRedelivered int
+	// NumWaiting is the number of pull requests waiting for messages.
+	NumWaiting int
+	// Rates are the ingress and ack rates of the consumer, only measured when requested by the query.
+	Rates Rates
+}
+
+// MetricWeights are the multipliers of each consumer metric in ConsumerState.Weighted.
+type MetricWeights struct {
+	Pending     int
+	AckPending  int
+	Redelivered int
+	Waiting     int
+}
+
+// Weighted returns the sum of the consumer metrics multiplied by their weights.
+func (s ConsumerState) Weighted(w MetricWeights) int {
+	return s.NumPending*w.Pending + s.NumAckPending*w.AckPending + s.NumRedelivered*w.Redelivered + s.NumWaiting*w.Waiting
+}
 
+// Backend looks up the state of a JetStream consumer.
+// Service queries the HTTP monitoring endpoint, JetStreamService the JetStream API.
+type Backend interface {
+	GetConsumerState(ctx context.Context, q ConsumerQuery) (ConsumerState, error)
+}
+
+// Prober reports the version of the NATS server at url, failing when it isn't reachable.
+type Prober interface {
+	ServerVersion(ctx context.Context, url string, auth *Auth) (string, error)
+}
+
+var (
+	_ Backend = &Service{}
+	_ Backend = &JetStreamService{}
+	_ Prober  = &Service{}
+	_ Prober  = &JetStreamService{}
+)
+
+// Service looks consumers up on the HTTP monitoring endpoint. The /jsz response of an account holds all
+// of its consumers, so it is cached per URL and account and shared by all rules for up to cacheTTL.
 type Service struct {
 	httpClient *http.Client
+	cacheTTL   time.Duration
+
+	mu    sync.Mutex
+	cache map[jszKey]*jszEntry
+	// clients are the per endpoint clients of rules with TLS credentials
+	clients map[clientKey]*authClient
+	health  *endpointHealth
+	rates   *rateTracker
+}
+
+type authClient struct {
+	client   *http.Client
+	lastUsed time.Time
+}
+
+type ServiceOption func(*Service)
+
+// WithCacheTTL sets how long a /jsz response is served from the cache, 0 disables caching.
+func WithCacheTTL(ttl time.Duration) ServiceOption {
+	return func(s *Service) {
+		s.cacheTTL = ttl
+	}
+}
+
+func NewService(httpClient *http.Client, options ...ServiceOption) *Service {
+	s := &Service{
+		httpClient: httpClient,
+		cacheTTL:   defaultCacheTTL,
+		cache:      make(map[jszKey]*jszEntry),
+		clients:    make(map[clientKey]*authClient),
+		health:     newEndpointHealth(),
+		rates:      newRateTracker(),
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+	return s
 }
 
-func NewService(httpClient *http.Client) *Service {
-	return &Service{httpClient: httpClient}
+type jszKey struct {
+	url     string
+	account string
+	// auth is the credentials fingerprint, responses aren't shared between rules authenticating differently
+	auth string
 }
 
-func (c *Service) GetPendingMessages(ctx context.Context, baseURL, streamName, consumerName string) (int, error) {
+// jszEntry is the last /jsz response of a key. Its mutex is held while fetching, so concurrent lookups
+// wait for the in-flight request instead of issuing their own.
+type jszEntry struct {
+	mu        sync.Mutex
+	resp      JszResponse
+	err       error
+	fetchedAt time.Time
+}
+
+func (c *Service) GetConsumerState(ctx context.Context, q ConsumerQuery) (ConsumerState, error) {
 
-	// NOTE: This uses an HTTP call because the assignment explicitly requires querying
-	// the monitoring endpoint. In production, I would use the official NATS Go client:
-	// https://pkg.go.dev/github.com/nats-io/nats.go
-	// which communicates over NATS using the JetStream wire protocol:
+	// NOTE: This queries the HTTP monitoring endpoint. JetStreamService is the alternative
+	// that talks to JetStream over the NATS protocol, for clusters where the monitoring
+	// port isn't reachable:
 	// https://docs.nats.io/reference/reference-protocols/nats_api_reference
 
-	logger := logf.FromContext(ctx)
+	accountName := q.Account
+	if accountName == "" {
+		accountName = GlobalAccountName
+	}
+	urls := make([]string, 0, len(q.FallbackURLs)+1)
+	for _, u := range append([]string{q.URL}, q.FallbackURLs...) {
+		if u != "" {
+			urls = append(urls, strings.TrimRight(u, "/"))
+		}
+	}
+	data, fetchedAt, err := c.jsz(ctx, urls, q.RoundRobin, accountName, q.Auth)
+	if err != nil {
+		return ConsumerState{}, err
+	}
+
+	account, ok := findAccount(data.AccountDetails, accountName)
+	if !ok {
+		return ConsumerState{}, &errs.AccountNotFoundErr{Account: accountName}
+	}
+	for _, stream := range account.StreamDetail {
+		if stream.Name == q.Stream {
+			for _, consumer := range stream.ConsumerDetail {
+				if consumer.Name == q.Consumer {
+					state := ConsumerState{
+						NumPending:     consumer.NumPending,
+						NumAckPending:  consumer.NumAckPending,
+						NumRedelivered: consumer.NumRedelivered,
+						NumWaiting:     consumer.NumWaiting,
+					}
+					if q.Rates {
+						// a cached response is measured at the time it was fetched, not at the time it was served
+						key := rateKey{endpoint: strings.Join(urls, ","), account: accountName, stream: q.Stream, consumer: q.Consumer}
+						state.Rates = c.rates.observe(key, stream.State.LastSeq, consumer.AckFloor.StreamSeq, fetchedAt)
+					}
+					return state, nil
+				}
+			}
+		}
+	}
+
+	return ConsumerState{}, &errs.ConsumerNotFoundErr{Account: accountName, Stream: q.Stream, Consumer: q.Consumer}
+}
+
+// jsz returns the /jsz response of the account, from the cache when it is not older than cacheTTL.
+// Failures are cached too, so that an unreachable server isn't queried by every rule in turn.
+// fetchedAt is the time the response was fetched.
+func (c *Service) jsz(ctx context.Context, urls []string, roundRobin bool, account string, auth *Auth) (resp JszResponse, fetchedAt time.Time, err error) {
+	if c.cacheTTL <= 0 {
+		resp, err = c.fetchAnyJsz(ctx, urls, roundRobin, account, auth)
+		return resp, time.Now(), err
+	}
+
+	// any endpoint of the cluster serves the same leader_only response
+	key := jszKey{url: strings.Join(urls, ","), account: account, auth: auth.fingerprint()}
+	c.mu.Lock()
+	entry, ok := c.cache[key]
+	if !ok {
+		c.sweepJsz()
+		entry = &jszEntry{}
+		c.cache[key] = entry
+	}
+	c.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if !entry.fetchedAt.IsZero() && time.Since(entry.fetchedAt) < c.cacheTTL {
+		return entry.resp, entry.fetchedAt, entry.err
+	}
+	resp, err = c.fetchAnyJsz(ctx, urls, roundRobin, account, auth)
+	if ctx.Err() != nil {
+		// the caller gave up, the next one should try again
+		return resp, time.Now(), err
+	}
+	entry.resp, entry.err, entry.fetchedAt = resp, err, time.Now()
+	return resp, entry.fetchedAt, err
+}
+
+// sweepJsz drops the expired responses, e.g. of accounts or credentials no rule uses anymore. Entries
+// being fetched are kept. c.mu must be held.
+func (c *Service) sweepJsz() {
+	for k, e := range c.cache {
+		if !e.mu.TryLock() {
+			continue
+		}
+		expired := !e.fetchedAt.IsZero() && time.Since(e.fetchedAt) >= c.cacheTTL
+		e.mu.Unlock()
+		if expired {
+			delete(c.cache, k)
+		}
+	}
+}
+
+// fetchAnyJsz fetches the /jsz response from the first endpoint that answers. Endpoints that failed
+// recently are tried last.
+func (c *Service) fetchAnyJsz(ctx context.Context, urls []string, roundRobin bool, account string, auth *Auth) (JszResponse, error) {
+	if len(urls) == 0 {
+		return JszResponse{}, errors.New("no NATS monitoring endpoint configured")
+	}
+	if len(urls) == 1 {
+		return c.fetchJsz(ctx, urls[0], account, auth)
+	}
+
+	var fetchErrs []error
+	for _, u := range c.health.order(urls, roundRobin) {
+		data, err := c.fetchJsz(ctx, u, account, auth)
+		if err == nil {
+			c.health.success(u)
+			return data, nil
+		}
+		if ctx.Err() != nil {
+			return JszResponse{}, err
+		}
+		logf.FromContext(ctx).V(1).Info("NATS monitoring endpoint failed, trying the next one", "url", u, "error", err.Error())
+		c.health.failure(u)
+		fetchErrs = append(fetchErrs, fmt.Errorf("%s: %w", u, err))
+	}
+	return JszResponse{}, fmt.Errorf("all %d NATS monitoring endpoints failed: %w", len(urls), errors.Join(fetchErrs...))
+}
+
+func (c *Service) fetchJsz(ctx context.Context, monitoringURL, account string, auth *Auth) (data JszResponse, err error) {
+	defer func(start time.Time) {
+		metrics.ObserveNatsRequest(backendMonitoring, time.Since(start), err)
+	}(time.Now())
+
+	// only the streams and consumers of the account, as reported by the meta leader
 	v := url.Values{}
-	v.Add("acc", GlobalAccountName)
-	v.Add("consumers", "1")
-	v.Add("leader_only", "1")
-	u := fmt.Sprintf("%s/jsz?%s", strings.TrimRight(baseURL, "/"), v.Encode())
+	v.Add("acc", account)
+	v.Add("streams", "true")
+	v.Add("consumers", "true")
+	v.Add("leader_only", "true")
+	if err := c.get(ctx, fmt.Sprintf("%s/jsz?%s", strings.TrimRight(monitoringURL, "/"), v.Encode()), monitoringURL, auth, &data); err != nil {
+		return JszResponse{}, err
+	}
+	return data, nil
+}
+
+// ServerVersion reads the server version from the /varz endpoint.
+func (c *Service) ServerVersion(ctx context.Context, monitoringURL string, auth *Auth) (version string, err error) {
+	defer func(start time.Time) {
+		metrics.ObserveNatsRequest(backendMonitoring, time.Since(start), err)
+	}(time.Now())
+
+	var data VarzResponse
+	if err := c.get(ctx, strings.TrimRight(monitoringURL, "/")+"/varz", monitoringURL, auth, &data); err != nil {
+		return "", err
+	}
+	return data.Version, nil
+}
+
+// get decodes the JSON response of a monitoring endpoint request into out.
+func (c *Service) get(ctx context.Context, u, monitoringURL string, auth *Auth, out any) error {
+	logger := logf.FromContext(ctx)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create new http request to %s: %w", u, err)
+		return fmt.Errorf("failed to create new http request to %s: %w", u, err)
 	}
-	resp, err := c.httpClient.Do(req)
+	auth.authorize(req)
+	httpClient, err := c.client(monitoringURL, auth)
 	if err != nil {
-		return 0, fmt.Errorf("failed to query nats subscriptions: %w", err)
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query nats monitoring endpoint: %w", err)
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -59,32 +332,67 @@ func (c *Service) GetPendingMessages(ctx context.Context, baseURL, streamName, c
 
 	if resp.StatusCode != http.StatusOK {
 		b, _ := io.ReadAll(resp.Body)
-		return 0, &errs.HTTPStatusCodeErr{
+		return &errs.HTTPStatusCodeErr{
 			Code: resp.StatusCode,
 			Body: b,
 		}
 	}
 
-	var data JszResponse
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return 0, fmt.Errorf("failed to decode JSON: %w", err)
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode JSON: %w", err)
 	}
+	return nil
+}
 
-	if len(data.AccountDetails) == 0 {
-		return 0, ErrNoAccountFound
+// client returns the HTTP client to reach the endpoint with. Credentials without TLS material use the
+// shared client, otherwise the endpoint and credentials get their own transport. Transports left unused,
+// e.g. after the credentials rotated, are closed.
+func (c *Service) client(monitoringURL string, auth *Auth) (*http.Client, error) {
+	tlsConfig, err := auth.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		return c.httpClient, nil
 	}
 
-	// assuming we only have one account, we can just look at the first one (that supposes to be $G)
-	account := data.AccountDetails[0]
-	for _, stream := range account.StreamDetail {
-		if stream.Name == streamName {
-			for _, consumer := range stream.ConsumerDetail {
-				if consumer.Name == consumerName {
-					return consumer.NumPending, nil
-				}
-			}
+	key := clientKey{url: strings.TrimRight(monitoringURL, "/"), fingerprint: auth.fingerprint()}
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, ac := range c.clients {
+		if now.Sub(ac.lastUsed) > idleClientTimeout {
+			ac.client.CloseIdleConnections()
+			delete(c.clients, k)
 		}
 	}
+	if ac, ok := c.clients[key]; ok {
+		ac.lastUsed = now
+		return ac.client, nil
+	}
 
-	return 0, fmt.Errorf("couldn't find NATS account <%s>, stram <%s>, consumer <%s>", GlobalAccountName, streamName, consumerName)
+	base, ok := c.httpClient.Transport.(*http.Transport)
+	if !ok || base == nil {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	transport := base.Clone()
+	transport.TLSClientConfig = tlsConfig
+	client := &http.Client{
+		Transport:     transport,
+		CheckRedirect: c.httpClient.CheckRedirect,
+		Jar:           c.httpClient.Jar,
+		Timeout:       c.httpClient.Timeout,
+	}
+	c.clients[key] = &authClient{client: client, lastUsed: now}
+	return client, nil
+}
+
+// findAccount looks the account up by name or id, in operator mode both hold the account public key.
+func findAccount(accounts []AccountDetails, name string) (AccountDetails, bool) {
+	for _, acc := range accounts {
+		if acc.Name == name || acc.ID == name {
+			return acc, true
+		}
+	}
+	return AccountDetails{}, false
 }
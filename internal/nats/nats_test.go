@@ -3,14 +3,18 @@ package nats
 import (
 	"context"
 	"encoding/json"
+	"encoding/pem"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/Av1shay/nats-scaler/pkg/errs"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -20,9 +24,12 @@ type mockNatsServer struct {
 	statusCode     int
 	forceError     bool
 	gotQueryParams url.Values
+	gotAuth        string
+	requests       atomic.Int32
 }
 
 func (m *mockNatsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.requests.Add(1)
 	if m.forceError {
 		http.Error(w, "simulated error", m.statusCode)
 		return
@@ -32,25 +39,31 @@ func (m *mockNatsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	require.Equal(m.t, "/jsz", r.URL.Path)
 
 	m.gotQueryParams = r.URL.Query()
+	m.gotAuth = r.Header.Get("Authorization")
 
 	w.WriteHeader(m.statusCode)
 	err := json.NewEncoder(w).Encode(m.resp)
 	require.NoError(m.t, err)
 }
 
-func TestService_GetPendingMessages(t *testing.T) {
+func TestService_GetConsumerState(t *testing.T) {
 	ctx := context.Background()
 	httpClient := &http.Client{Timeout: 5 * time.Second}
-	s := NewService(httpClient)
+	s := NewService(httpClient, WithCacheTTL(0))
 
 	natsServer := &mockNatsServer{
 		t: t,
 		resp: JszResponse{
 			AccountDetails: []AccountDetails{{
+				Name: GlobalAccountName,
+				ID:   GlobalAccountName,
 				StreamDetail: []StreamDetail{
 					{Name: "EVENTS", ConsumerDetail: []ConsumerDetail{{
-						Name:       "xxx",
-						NumPending: 250,
+						Name:           "xxx",
+						NumPending:     250,
+						NumAckPending:  12,
+						NumRedelivered: 2,
+						NumWaiting:     1,
 					}}},
 				},
 			}},
@@ -60,25 +73,283 @@ func TestService_GetPendingMessages(t *testing.T) {
 	ts := httptest.NewServer(natsServer)
 	t.Cleanup(ts.Close)
 
-	res, err := s.GetPendingMessages(ctx, ts.URL, "EVENTS", "xxx")
+	res, err := s.GetConsumerState(ctx, ConsumerQuery{URL: ts.URL, Stream: "EVENTS", Consumer: "xxx"})
 	require.NoError(t, err)
-	require.Equal(t, 250, res)
-	require.Equal(t, url.Values{"acc": {"$G"}, "consumers": {"1"}, "leader_only": {"1"}}, natsServer.gotQueryParams)
+	require.Equal(t, ConsumerState{NumPending: 250, NumAckPending: 12, NumRedelivered: 2, NumWaiting: 1}, res)
+	require.Equal(t, url.Values{"acc": {"$G"}, "streams": {"true"}, "consumers": {"true"}, "leader_only": {"true"}}, natsServer.gotQueryParams)
 
-	_, err = s.GetPendingMessages(ctx, ts.URL, "NOT-EXIST", "xxx")
+	var consumerErr *errs.ConsumerNotFoundErr
+	_, err = s.GetConsumerState(ctx, ConsumerQuery{URL: ts.URL, Stream: "NOT-EXIST", Consumer: "xxx"})
+	require.ErrorAs(t, err, &consumerErr)
 	require.ErrorContains(t, err, "couldn't find NATS account")
 
+	var accErr *errs.AccountNotFoundErr
+	_, err = s.GetConsumerState(ctx, ConsumerQuery{URL: ts.URL, Account: "ORDERS", Stream: "EVENTS", Consumer: "xxx"})
+	require.ErrorAs(t, err, &accErr)
+	require.Equal(t, "ORDERS", accErr.Account)
+	require.Equal(t, url.Values{"acc": {"ORDERS"}, "streams": {"true"}, "consumers": {"true"}, "leader_only": {"true"}}, natsServer.gotQueryParams)
+
 	natsServer.resp = struct {
 		AccountDetails []AccountDetails `json:"account_details"`
 	}{AccountDetails: nil}
-	_, err = s.GetPendingMessages(ctx, ts.URL, "EVENTS", "xxx")
-	require.ErrorIs(t, err, ErrNoAccountFound)
+	_, err = s.GetConsumerState(ctx, ConsumerQuery{URL: ts.URL, Stream: "EVENTS", Consumer: "xxx"})
+	require.ErrorAs(t, err, &accErr)
+	require.Equal(t, GlobalAccountName, accErr.Account)
 
 	natsServer.statusCode = 500
-	res, err = s.GetPendingMessages(ctx, ts.URL, "EVENTS", "xxx")
+	res, err = s.GetConsumerState(ctx, ConsumerQuery{URL: ts.URL, Stream: "EVENTS", Consumer: "xxx"})
 	var scerr *errs.HTTPStatusCodeErr
 	require.ErrorAs(t, err, &scerr)
-	require.Equal(t, 0, res)
+	require.Equal(t, ConsumerState{}, res)
 	require.Equal(t, 500, scerr.Code)
 	require.Equal(t, "{\"account_details\":null}\n", string(scerr.Body))
 }
+
+func TestService_GetConsumerState_Account(t *testing.T) {
+	ctx := context.Background()
+	s := NewService(&http.Client{Timeout: 5 * time.Second})
+
+	const accountKey = "ACZ5ZFMCHRNDXEXYAZHKWXGMTEMPK4BDFVQT6RFNFXYUZQ4FB62B4KWP"
+	natsServer := &mockNatsServer{
+		t: t,
+		resp: JszResponse{
+			AccountDetails: []AccountDetails{
+				{Name: "OTHER", ID: "OTHER", StreamDetail: []StreamDetail{
+					{Name: "EVENTS", ConsumerDetail: []ConsumerDetail{{Name: "xxx", NumPending: 1}}},
+				}},
+				{Name: accountKey, ID: accountKey, StreamDetail: []StreamDetail{
+					{Name: "EVENTS", ConsumerDetail: []ConsumerDetail{{Name: "xxx", NumPending: 42}}},
+				}},
+			},
+		},
+		statusCode: 200,
+	}
+	ts := httptest.NewServer(natsServer)
+	t.Cleanup(ts.Close)
+
+	res, err := s.GetConsumerState(ctx, ConsumerQuery{URL: ts.URL, Account: accountKey, Stream: "EVENTS", Consumer: "xxx"})
+	require.NoError(t, err)
+	require.Equal(t, 42, res.NumPending)
+	require.Equal(t, accountKey, natsServer.gotQueryParams.Get("acc"))
+}
+
+func TestConsumerState_Weighted(t *testing.T) {
+	state := ConsumerState{NumPending: 100, NumAckPending: 20, NumRedelivered: 3, NumWaiting: 1}
+
+	require.Equal(t, 100, state.Weighted(MetricWeights{Pending: 1}))
+	require.Equal(t, 20, state.Weighted(MetricWeights{AckPending: 1}))
+	require.Equal(t, 140, state.Weighted(MetricWeights{Pending: 1, AckPending: 2}))
+	require.Equal(t, 134, state.Weighted(MetricWeights{Pending: 1, AckPending: 1, Redelivered: 4, Waiting: 2}))
+	require.Equal(t, 0, state.Weighted(MetricWeights{}))
+}
+
+func TestService_GetConsumerState_Cache(t *testing.T) {
+	ctx := context.Background()
+	s := NewService(&http.Client{Timeout: 5 * time.Second}, WithCacheTTL(time.Hour))
+
+	natsServer := &mockNatsServer{
+		t: t,
+		resp: JszResponse{
+			AccountDetails: []AccountDetails{{
+				Name: GlobalAccountName,
+				StreamDetail: []StreamDetail{
+					{Name: "EVENTS", ConsumerDetail: []ConsumerDetail{{Name: "xxx", NumPending: 1}, {Name: "yyy", NumPending: 2}}},
+					{Name: "ORDERS", ConsumerDetail: []ConsumerDetail{{Name: "xxx", NumPending: 3}}},
+				},
+			}},
+		},
+		statusCode: 200,
+	}
+	ts := httptest.NewServer(natsServer)
+	t.Cleanup(ts.Close)
+
+	// concurrent lookups of different consumers share a single request
+	var wg sync.WaitGroup
+	for _, q := range []ConsumerQuery{
+		{URL: ts.URL, Stream: "EVENTS", Consumer: "xxx"},
+		{URL: ts.URL, Stream: "EVENTS", Consumer: "yyy"},
+		{URL: ts.URL + "/", Stream: "ORDERS", Consumer: "xxx"},
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.GetConsumerState(ctx, q)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(1), natsServer.requests.Load())
+
+	res, err := s.GetConsumerState(ctx, ConsumerQuery{URL: ts.URL, Stream: "ORDERS", Consumer: "xxx"})
+	require.NoError(t, err)
+	require.Equal(t, 3, res.NumPending)
+	require.Equal(t, int32(1), natsServer.requests.Load())
+
+	// other accounts are fetched separately
+	var accErr *errs.AccountNotFoundErr
+	_, err = s.GetConsumerState(ctx, ConsumerQuery{URL: ts.URL, Account: "ORDERS", Stream: "ORDERS", Consumer: "xxx"})
+	require.ErrorAs(t, err, &accErr)
+	require.Equal(t, int32(2), natsServer.requests.Load())
+
+	// stale entries are fetched again
+	s.cacheTTL = time.Nanosecond
+	natsServer.statusCode = 500
+	_, err = s.GetConsumerState(ctx, ConsumerQuery{URL: ts.URL, Stream: "ORDERS", Consumer: "xxx"})
+	var scerr *errs.HTTPStatusCodeErr
+	require.ErrorAs(t, err, &scerr)
+	require.Equal(t, int32(3), natsServer.requests.Load())
+
+	// expired entries are dropped once another one is added
+	require.Len(t, s.cache, 2)
+	_, err = s.GetConsumerState(ctx, ConsumerQuery{URL: ts.URL, Account: "EVENTS", Stream: "EVENTS", Consumer: "xxx"})
+	require.ErrorAs(t, err, &scerr)
+	require.Len(t, s.cache, 1)
+	require.Contains(t, s.cache, jszKey{url: ts.URL, account: "EVENTS"})
+}
+
+func TestService_GetConsumerState_Rates(t *testing.T) {
+	ctx := context.Background()
+	s := NewService(&http.Client{Timeout: 5 * time.Second}, WithCacheTTL(0))
+
+	consumer := ConsumerDetail{Name: "xxx", NumPending: 100, AckFloor: SequenceInfo{ConsumerSeq: 850, StreamSeq: 900}}
+	stream := StreamDetail{Name: "EVENTS", State: StreamState{LastSeq: 1000}, ConsumerDetail: []ConsumerDetail{consumer}}
+	natsServer := &mockNatsServer{
+		t:          t,
+		resp:       JszResponse{AccountDetails: []AccountDetails{{Name: GlobalAccountName, StreamDetail: []StreamDetail{stream}}}},
+		statusCode: 200,
+	}
+	ts := httptest.NewServer(natsServer)
+	t.Cleanup(ts.Close)
+
+	q := ConsumerQuery{URL: ts.URL, Stream: "EVENTS", Consumer: "xxx", Rates: true}
+	res, err := s.GetConsumerState(ctx, q)
+	require.NoError(t, err)
+	require.Equal(t, Rates{}, res.Rates)
+
+	// take the first reading 10s ago
+	key := rateKey{endpoint: ts.URL, account: GlobalAccountName, stream: "EVENTS", consumer: "xxx"}
+	reading := s.rates.readings[key]
+	reading.at = reading.at.Add(-10 * time.Second)
+	s.rates.readings[key] = reading
+
+	stream.State.LastSeq = 1500
+	stream.ConsumerDetail[0].AckFloor.StreamSeq = 1100
+	natsServer.resp = JszResponse{AccountDetails: []AccountDetails{{Name: GlobalAccountName, StreamDetail: []StreamDetail{stream}}}}
+	res, err = s.GetConsumerState(ctx, q)
+	require.NoError(t, err)
+	require.True(t, res.Rates.Known)
+	require.InDelta(t, 50, res.Rates.Ingress, 1)
+	require.InDelta(t, 20, res.Rates.Ack, 1)
+
+	// rates are only measured when requested
+	q.Rates = false
+	res, err = s.GetConsumerState(ctx, q)
+	require.NoError(t, err)
+	require.Equal(t, Rates{}, res.Rates)
+}
+
+func TestService_GetConsumerState_Auth(t *testing.T) {
+	ctx := context.Background()
+	s := NewService(&http.Client{Timeout: 5 * time.Second})
+
+	natsServer := &mockNatsServer{
+		t: t,
+		resp: JszResponse{
+			AccountDetails: []AccountDetails{{
+				Name:         GlobalAccountName,
+				StreamDetail: []StreamDetail{{Name: "EVENTS", ConsumerDetail: []ConsumerDetail{{Name: "xxx", NumPending: 5}}}},
+			}},
+		},
+		statusCode: 200,
+	}
+	ts := httptest.NewTLSServer(natsServer)
+	t.Cleanup(ts.Close)
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+
+	// the server certificate isn't trusted without the CA
+	_, err := s.GetConsumerState(ctx, ConsumerQuery{URL: ts.URL, Stream: "EVENTS", Consumer: "xxx"})
+	require.ErrorContains(t, err, "certificate")
+
+	q := ConsumerQuery{URL: ts.URL, Stream: "EVENTS", Consumer: "xxx", Auth: &Auth{CACert: caCert, Token: "t0ken"}}
+	res, err := s.GetConsumerState(ctx, q)
+	require.NoError(t, err)
+	require.Equal(t, 5, res.NumPending)
+	require.Equal(t, "Bearer t0ken", natsServer.gotAuth)
+
+	// rotated credentials aren't served the response cached for the old ones
+	q.Auth = &Auth{CACert: caCert, Username: "scaler", Password: "s3cr3t"}
+	_, err = s.GetConsumerState(ctx, q)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), natsServer.requests.Load())
+	user, pass, ok := (&http.Request{Header: http.Header{"Authorization": {natsServer.gotAuth}}}).BasicAuth()
+	require.True(t, ok)
+	require.Equal(t, "scaler", user)
+	require.Equal(t, "s3cr3t", pass)
+
+	q.Auth = &Auth{CACert: []byte("not a certificate")}
+	_, err = s.GetConsumerState(ctx, q)
+	require.ErrorContains(t, err, "failed to parse the CA certificate")
+}
+
+func TestService_ServerVersion(t *testing.T) {
+	ctx := context.Background()
+	s := NewService(&http.Client{Timeout: 5 * time.Second})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/varz" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(VarzResponse{ServerID: "NAB", Version: "2.11.4"})
+	}))
+	t.Cleanup(ts.Close)
+
+	version, err := s.ServerVersion(ctx, ts.URL+"/", nil)
+	require.NoError(t, err)
+	require.Equal(t, "2.11.4", version)
+
+	_, err = s.ServerVersion(ctx, ts.URL+"/nats", nil)
+	var scerr *errs.HTTPStatusCodeErr
+	require.ErrorAs(t, err, &scerr)
+	require.Equal(t, http.StatusNotFound, scerr.Code)
+}
+
+func TestService_GetConsumerState_Failover(t *testing.T) {
+	ctx := context.Background()
+	s := NewService(&http.Client{Timeout: 5 * time.Second}, WithCacheTTL(0))
+
+	resp := JszResponse{
+		AccountDetails: []AccountDetails{{
+			Name:         GlobalAccountName,
+			StreamDetail: []StreamDetail{{Name: "EVENTS", ConsumerDetail: []ConsumerDetail{{Name: "xxx", NumPending: 7}}}},
+		}},
+	}
+	// only the meta leader answers leader_only requests
+	follower := &mockNatsServer{t: t, forceError: true, statusCode: http.StatusBadRequest}
+	leader := &mockNatsServer{t: t, resp: resp, statusCode: 200}
+	followerServer := httptest.NewServer(follower)
+	t.Cleanup(followerServer.Close)
+	leaderServer := httptest.NewServer(leader)
+	t.Cleanup(leaderServer.Close)
+
+	q := ConsumerQuery{URL: followerServer.URL, FallbackURLs: []string{leaderServer.URL}, Stream: "EVENTS", Consumer: "xxx"}
+	res, err := s.GetConsumerState(ctx, q)
+	require.NoError(t, err)
+	require.Equal(t, 7, res.NumPending)
+	require.Equal(t, int32(1), follower.requests.Load())
+	require.Equal(t, int32(1), leader.requests.Load())
+
+	// the failed endpoint is skipped while it backs off
+	_, err = s.GetConsumerState(ctx, q)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), follower.requests.Load())
+	require.Equal(t, int32(2), leader.requests.Load())
+
+	leader.forceError, leader.statusCode = true, http.StatusServiceUnavailable
+	_, err = s.GetConsumerState(ctx, q)
+	require.ErrorContains(t, err, "all 2 NATS monitoring endpoints failed")
+	var scerr *errs.HTTPStatusCodeErr
+	require.ErrorAs(t, err, &scerr)
+	require.Equal(t, int32(2), follower.requests.Load())
+	require.Equal(t, int32(3), leader.requests.Load())
+}
@@ -0,0 +1,73 @@
+package nats
+
+import (
+	"sync"
+	"time"
+)
+
+// minRateInterval is the shortest time between two readings a rate is measured over. A reading taken
+// sooner, typically the same cached /jsz response, keeps the rates of the previous one.
+const minRateInterval = time.Second
+
+// rateTracker derives the rates of consumers from successive readings of the stream last sequence,
+// which advances with every message appended to the stream, and of the consumer ack floor, which
+// advances as the consumer acknowledges them.
+type rateTracker struct {
+	mu       sync.Mutex
+	readings map[rateKey]seqReading
+}
+
+type rateKey struct {
+	// endpoint is the server, or the set of servers, the readings are taken from
+	endpoint string
+	account  string
+	stream   string
+	consumer string
+}
+
+// seqReading is the last reading of a consumer and the rates measured up to it.
+type seqReading struct {
+	lastSeq  uint64
+	ackFloor uint64
+	at       time.Time
+	rates    Rates
+}
+
+// Rates are the message rates of a consumer in messages per second.
+type Rates struct {
+	// Ingress is the rate messages are appended to the stream.
+	Ingress float64
+	// Ack is the rate the consumer acknowledges messages.
+	Ack float64
+	// Known is false until two readings were taken far enough apart to measure the rates over.
+	Known bool
+}
+
+func newRateTracker() *rateTracker {
+	return &rateTracker{readings: make(map[rateKey]seqReading)}
+}
+
+// observe records a reading taken at the given time and returns the rates measured since the previous one.
+func (t *rateTracker) observe(key rateKey, lastSeq, ackFloor uint64, at time.Time) Rates {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev, ok := t.readings[key]
+	switch {
+	case !ok || lastSeq < prev.lastSeq || ackFloor < prev.ackFloor:
+		// the first reading, or the stream or consumer was recreated and the sequences started over
+		t.readings[key] = seqReading{lastSeq: lastSeq, ackFloor: ackFloor, at: at}
+		return Rates{}
+	case at.Sub(prev.at) < minRateInterval:
+		return prev.rates
+	}
+
+	elapsed := at.Sub(prev.at).Seconds()
+	rates := Rates{
+		Ingress: float64(lastSeq-prev.lastSeq) / elapsed,
+		Ack:     float64(ackFloor-prev.ackFloor) / elapsed,
+		Known:   true,
+	}
+	t.readings[key] = seqReading{lastSeq: lastSeq, ackFloor: ackFloor, at: at, rates: rates}
+	return rates
+}
@@ -0,0 +1,38 @@
+package nats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateTracker(t *testing.T) {
+	now := time.Now()
+	r := newRateTracker()
+	key := rateKey{endpoint: "http://a", account: GlobalAccountName, stream: "EVENTS", consumer: "xxx"}
+
+	// the first reading has nothing to measure against
+	require.Equal(t, Rates{}, r.observe(key, 1000, 900, now))
+
+	now = now.Add(10 * time.Second)
+	require.Equal(t, Rates{Ingress: 50, Ack: 20, Known: true}, r.observe(key, 1500, 1100, now))
+
+	// readings too close to the last one, e.g. from the cache, keep its rates
+	require.Equal(t, Rates{Ingress: 50, Ack: 20, Known: true}, r.observe(key, 1500, 1100, now))
+	require.Equal(t, Rates{Ingress: 50, Ack: 20, Known: true}, r.observe(key, 1510, 1100, now.Add(500*time.Millisecond)))
+
+	now = now.Add(4 * time.Second)
+	require.Equal(t, Rates{Ingress: 0, Ack: 25, Known: true}, r.observe(key, 1500, 1200, now))
+
+	// consumers are tracked separately
+	other := key
+	other.consumer = "yyy"
+	require.Equal(t, Rates{}, r.observe(other, 1500, 0, now))
+
+	// sequences going backwards start over
+	now = now.Add(10 * time.Second)
+	require.Equal(t, Rates{}, r.observe(key, 10, 5, now))
+	now = now.Add(10 * time.Second)
+	require.Equal(t, Rates{Ingress: 1, Ack: 0.5, Known: true}, r.observe(key, 20, 10, now))
+}
@@ -0,0 +1,82 @@
+package scaler
+
+import (
+	"math"
+	"time"
+
+	internalTypes "github.com/Av1shay/nats-scaler/internal/types"
+)
+
+type sampleKey struct {
+	target  internalTypes.ScaleTarget
+	trigger string
+}
+
+type timestampedSample struct {
+	value int
+	at    time.Time
+}
+
+// predict records the trigger value and returns the value projected LookAhead ahead of now by fitting
+// the model over the recent samples. The value isn't recorded less than Interval after the last sample.
+// Until there are enough samples to fit, the value itself is returned.
+func (s *RealScaler) predict(target internalTypes.ScaleTarget, t internalTypes.Trigger, now time.Time, p internalTypes.PredictionParams) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := sampleKey{target: target, trigger: t.Name}
+	recent := s.samples[key]
+	samples := make([]timestampedSample, 0, p.Samples)
+	if len(recent) == 0 || now.Sub(recent[0].at) >= p.Interval {
+		samples = append(samples, timestampedSample{value: t.Value, at: now})
+	}
+	for _, sample := range recent {
+		if len(samples) >= p.Samples || (p.MaxAge > 0 && now.Sub(sample.at) > p.MaxAge) {
+			break
+		}
+		samples = append(samples, sample)
+	}
+	s.samples[key] = samples
+	if len(samples) < 2 {
+		return t.Value
+	}
+
+	at := now.Add(p.LookAhead)
+	var projected float64
+	switch p.Model {
+	case internalTypes.PredictionModelExponential:
+		// log1p keeps empty backlogs in the fit
+		projected = math.Expm1(linearFit(samples, at, func(v int) float64 { return math.Log1p(float64(v)) }))
+	default:
+		projected = linearFit(samples, at, func(v int) float64 { return float64(v) })
+	}
+	switch {
+	case math.IsNaN(projected) || projected < 0:
+		return 0
+	case projected > math.MaxInt32:
+		return math.MaxInt32
+	}
+	return int(math.Round(projected))
+}
+
+// linearFit fits a least squares line through the transformed samples and returns its value at the given
+// time. Samples all taken at the same time have no trend, their mean is returned.
+func linearFit(samples []timestampedSample, at time.Time, transform func(int) float64) float64 {
+	origin := samples[len(samples)-1].at
+	n := float64(len(samples))
+	var meanX, meanY float64
+	for _, sample := range samples {
+		meanX += sample.at.Sub(origin).Seconds() / n
+		meanY += transform(sample.value) / n
+	}
+	var cov, variance float64
+	for _, sample := range samples {
+		dx := sample.at.Sub(origin).Seconds() - meanX
+		cov += dx * (transform(sample.value) - meanY)
+		variance += dx * dx
+	}
+	if variance == 0 {
+		return meanY
+	}
+	return meanY + cov/variance*(at.Sub(origin).Seconds()-meanX)
+}
@@ -3,12 +3,13 @@ package scaler
 import (
 	"context"
 	"fmt"
+	"math"
 	"sync"
 	"time"
 
 	internalTypes "github.com/Av1shay/nats-scaler/internal/types"
-	appsv1 "k8s.io/api/apps/v1"
-	"k8s.io/apimachinery/pkg/types"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -17,12 +18,23 @@ const (
 	defaultCooldown = 15 * time.Second
 )
 
-var (
-	lastScaleMap = sync.Map{} // map[types.NamespacedName]time.Time
-)
-
 type RealScaler struct {
 	cooldown time.Duration
+
+	// recommendations holds the recent replica recommendations per target, used by the stabilization
+	// windows. Like the HPA, this history is kept in memory and rebuilt after a restart.
+	mu              sync.Mutex
+	recommendations map[internalTypes.ScaleTarget][]timestampedRecommendation
+	// samples holds the recent values per trigger, used by the prediction. Kept in memory as well.
+	samples map[sampleKey][]timestampedSample
+	// lastScaled is the last time each target was scaled. The status of the rule lags behind it when the
+	// scale itself triggers the next reconcile, so the later of the two is used for the cooldown.
+	lastScaled map[internalTypes.ScaleTarget]time.Time
+}
+
+type timestampedRecommendation struct {
+	replicas int32
+	at       time.Time
 }
 
 type Option func(*RealScaler)
@@ -34,7 +46,12 @@ func WithCooldown(cooldown time.Duration) Option {
 }
 
 func NewScaler(options ...Option) *RealScaler {
-	s := &RealScaler{cooldown: defaultCooldown}
+	s := &RealScaler{
+		cooldown:        defaultCooldown,
+		recommendations: make(map[internalTypes.ScaleTarget][]timestampedRecommendation),
+		samples:         make(map[sampleKey][]timestampedSample),
+		lastScaled:      make(map[internalTypes.ScaleTarget]time.Time),
+	}
 	for _, opt := range options {
 		opt(s)
 	}
@@ -44,50 +61,300 @@ func NewScaler(options ...Option) *RealScaler {
 func (s *RealScaler) ReconcileScale(
 	ctx context.Context,
 	k8s client.Client,
-	deployment types.NamespacedName,
+	target internalTypes.ScaleTarget,
 	rule internalTypes.ScalerParams,
-	pendingMsgs int,
-) error {
+) (internalTypes.ScaleResult, error) {
 	logger := logf.FromContext(ctx)
 	now := time.Now()
 
-	// make sure we are not scaling too aggressively
-	if val, ok := lastScaleMap.Load(deployment); ok {
-		if last, ok := val.(time.Time); ok && now.Sub(last) < s.cooldown {
-			logger.Info(fmt.Sprintf("Cooldown in effect — skipping scaling. (pending: %d)", pendingMsgs))
-			return nil
+	obj, err := newTargetObject(k8s, target)
+	if err != nil {
+		return internalTypes.ScaleResult{}, err
+	}
+	var scale autoscalingv1.Scale
+	if err := k8s.SubResource("scale").Get(ctx, obj, &scale); err != nil {
+		return internalTypes.ScaleResult{}, fmt.Errorf("failed to get scale of %s %s: %w", target.GroupVersionKind.Kind, target.NamespacedName, err)
+	}
+
+	current := scale.Spec.Replicas
+	res := internalTypes.ScaleResult{TargetUID: scale.UID, CurrentReplicas: current, LastScaleTime: rule.LastScaleTime}
+	s.mu.Lock()
+	if at := s.lastScaled[target]; at.After(res.LastScaleTime) {
+		res.LastScaleTime = at
+	}
+	s.mu.Unlock()
+
+	if rule.FallbackReplicas != nil {
+		desired, limited := clampReplicas(rule, int64(*rule.FallbackReplicas))
+		res.DesiredReplicas, res.Limited, res.Fallback = desired, limited, true
+		if desired == current {
+			return res, nil
 		}
+		return s.updateScale(ctx, k8s, obj, &scale, target, res, "metrics unavailable, fallback")
 	}
 
-	var deploy appsv1.Deployment
-	if err := k8s.Get(ctx, deployment, &deploy); err != nil {
-		return fmt.Errorf("failed to get deployment: %w", err)
+	// triggers holds the values the recommendations are computed from, the projected ones when higher
+	triggers := rule.Triggers
+	predicted := make([]int, len(rule.Triggers))
+	if p := rule.Prediction; p != nil {
+		triggers = make([]internalTypes.Trigger, len(rule.Triggers))
+		for i, t := range rule.Triggers {
+			predicted[i] = s.predict(target, t, now, *p)
+			t.Value = max(t.Value, predicted[i])
+			triggers[i] = t
+		}
 	}
 
-	current := *deploy.Spec.Replicas
-	desired := current
+	var desired int32
+	var why string
+	stz := rule.ScaleToZero
+	if stz != nil && current == 0 {
+		// activation is not subject to the stabilization windows or the cooldown, a consumer at zero
+		// replicas makes no progress until it is woken up.
+		for _, t := range triggers {
+			if t.Pending >= stz.ActivationThreshold {
+				desired = min(stz.ActivationReplicas, rule.MaxReplicas)
+				res.Activated = true
+				res.ActiveTrigger = t.Name
+				why = fmt.Sprintf("activation: pending %d >= %d", t.Pending, stz.ActivationThreshold)
+				break
+			}
+		}
+		res.DesiredReplicas = desired
+		if desired == 0 {
+			return res, nil
+		}
+		return s.updateScale(ctx, k8s, obj, &scale, target, res, why)
+	}
 
-	if pendingMsgs > rule.ScaleUpThreshold && current < rule.MaxReplicas {
-		desired = current + 1
-		if desired > rule.MaxReplicas {
-			desired = rule.MaxReplicas
+	if stz != nil {
+		// the target only goes below one replica once the consumer has been idle for long enough
+		rule.MinReplicas = max(rule.MinReplicas, 1)
+	}
+	raw := int64(current)
+	pending := 0
+	for i, t := range triggers {
+		var rec int64
+		var recWhy string
+		switch rule.Policy {
+		case internalTypes.ScalingPolicyProportional:
+			rec, recWhy = proportionalRecommendation(rule, t)
+		case internalTypes.ScalingPolicyThroughput:
+			rec, recWhy = throughputRecommendation(current, rule, t)
+		default:
+			rec, recWhy = stepRecommendation(current, t)
+		}
+		observed := rule.Triggers[i].Value
+		if t.Value > observed && recWhy != "" {
+			recWhy = fmt.Sprintf("%s, predicted from %d", recWhy, observed)
 		}
-		logger.Info(fmt.Sprintf("Scaling up: %d → %d (pending: %d > %d)", current, desired, pendingMsgs, rule.ScaleUpThreshold))
-	} else if pendingMsgs < rule.ScaleDownThreshold && current > rule.MinReplicas {
-		desired = current - 1
-		if desired < rule.MinReplicas {
-			desired = rule.MinReplicas
+		if len(triggers) > 1 {
+			recWhy = fmt.Sprintf("%s: %s", t.Name, recWhy)
+		}
+		recDesired, _ := clampReplicas(rule, rec)
+		res.Triggers = append(res.Triggers, internalTypes.TriggerResult{
+			Name:            t.Name,
+			Value:           observed,
+			Predicted:       predicted[i],
+			DesiredReplicas: recDesired,
+		})
+		// like the HPA with multiple metrics, the trigger asking for the most replicas wins
+		if i == 0 || rec > raw {
+			raw, why, pending = rec, recWhy, t.Value
+			res.ActiveTrigger = t.Name
+		}
+	}
+	desired, res.Limited = clampReplicas(rule, raw)
+	if stabilized := s.stabilize(target, current, desired, rule, now); stabilized != desired {
+		res.Stabilized = true
+		desired = stabilized
+	}
+	if stz != nil && !stz.IdleSince.IsZero() && now.Sub(stz.IdleSince) >= stz.IdleAfter {
+		desired = 0
+		res.Idle = true
+		res.Limited = false
+		why = fmt.Sprintf("idle since %s", stz.IdleSince.Format(time.RFC3339))
+	}
+	res.DesiredReplicas = desired
+
+	if desired == current {
+		return res, nil
+	}
+
+	// make sure we are not scaling too aggressively
+	if !res.LastScaleTime.IsZero() && now.Sub(res.LastScaleTime) < s.cooldown {
+		logger.Info(fmt.Sprintf("Cooldown in effect — skipping scaling. (pending: %d)", pending))
+		res.CooldownActive = true
+		return res, nil
+	}
+
+	return s.updateScale(ctx, k8s, obj, &scale, target, res, why)
+}
+
+// SetScale scales the target to the given replicas, without cooldown, and forgets its recommendation
+// history. It is used when the replicas no longer follow the triggers: while the rule is suspended with
+// pinned replicas, and when the rule is deleted.
+func (s *RealScaler) SetScale(
+	ctx context.Context,
+	k8s client.Client,
+	target internalTypes.ScaleTarget,
+	replicas int32,
+	why string,
+) (internalTypes.ScaleResult, error) {
+	s.mu.Lock()
+	delete(s.recommendations, target)
+	for key := range s.samples {
+		if key.target == target {
+			delete(s.samples, key)
+		}
+	}
+	s.mu.Unlock()
+
+	obj, err := newTargetObject(k8s, target)
+	if err != nil {
+		return internalTypes.ScaleResult{}, err
+	}
+	var scale autoscalingv1.Scale
+	if err := k8s.SubResource("scale").Get(ctx, obj, &scale); err != nil {
+		return internalTypes.ScaleResult{}, fmt.Errorf("failed to get scale of %s %s: %w", target.GroupVersionKind.Kind, target.NamespacedName, err)
+	}
+	res := internalTypes.ScaleResult{TargetUID: scale.UID, CurrentReplicas: scale.Spec.Replicas, DesiredReplicas: replicas}
+	if replicas == scale.Spec.Replicas {
+		return res, nil
+	}
+	return s.updateScale(ctx, k8s, obj, &scale, target, res, why)
+}
+
+// updateScale sets the target replicas to res.DesiredReplicas through the scale subresource.
+func (s *RealScaler) updateScale(
+	ctx context.Context,
+	k8s client.Client,
+	obj client.Object,
+	scale *autoscalingv1.Scale,
+	target internalTypes.ScaleTarget,
+	res internalTypes.ScaleResult,
+	why string,
+) (internalTypes.ScaleResult, error) {
+	logger := logf.FromContext(ctx)
+	current, desired := res.CurrentReplicas, res.DesiredReplicas
+	if desired > current {
+		logger.Info(fmt.Sprintf("Scaling up: %d → %d (%s)", current, desired, why))
+	} else {
+		logger.Info(fmt.Sprintf("Scaling down: %d → %d (%s)", current, desired, why))
+	}
+
+	scale.Spec.Replicas = desired
+	if err := k8s.SubResource("scale").Update(ctx, obj, client.WithSubResourceBody(scale)); err != nil {
+		return res, fmt.Errorf("failed to update scale of %s %s: %w", target.GroupVersionKind.Kind, target.NamespacedName, err)
+	}
+	res.Scaled = true
+	res.LastScaleTime = time.Now()
+	s.mu.Lock()
+	s.lastScaled[target] = res.LastScaleTime
+	s.mu.Unlock()
+
+	return res, nil
+}
+
+// stabilize records the recommendation and returns the replica count allowed by the stabilization
+// windows: scaling up goes no higher than the lowest recommendation within the scale-up window and scaling
+// down goes no lower than the highest recommendation within the scale-down window, as the HPA does.
+func (s *RealScaler) stabilize(target internalTypes.ScaleTarget, current, recommended int32, rule internalTypes.ScalerParams, now time.Time) int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	maxWindow := max(rule.ScaleUpStabilizationWindow, rule.ScaleDownStabilizationWindow)
+	recs := []timestampedRecommendation{{replicas: recommended, at: now}}
+	for _, rec := range s.recommendations[target] {
+		if now.Sub(rec.at) <= maxWindow {
+			recs = append(recs, rec)
 		}
-		logger.Info(fmt.Sprintf("Scaling down: %d → %d (pending: %d < %d)", current, desired, pendingMsgs, rule.ScaleDownThreshold))
 	}
+	s.recommendations[target] = recs
 
-	if desired != current {
-		deploy.Spec.Replicas = &desired
-		if err := k8s.Update(ctx, &deploy); err != nil {
-			return fmt.Errorf("failed to update deployment: %w", err)
+	upRecommendation, downRecommendation := recommended, recommended
+	for _, rec := range recs {
+		if now.Sub(rec.at) <= rule.ScaleUpStabilizationWindow {
+			upRecommendation = min(upRecommendation, rec.replicas)
+		}
+		if now.Sub(rec.at) <= rule.ScaleDownStabilizationWindow {
+			downRecommendation = max(downRecommendation, rec.replicas)
 		}
-		lastScaleMap.Store(deployment, now)
 	}
 
-	return nil
+	switch {
+	case current < upRecommendation:
+		return upRecommendation
+	case downRecommendation < current:
+		return downRecommendation
+	}
+	return current
+}
+
+// stepRecommendation moves the replicas by one when the trigger value crosses a threshold.
+func stepRecommendation(current int32, t internalTypes.Trigger) (desired int64, why string) {
+	desired = int64(current)
+	if t.Value > t.ScaleUpThreshold {
+		desired++
+		why = fmt.Sprintf("pending: %d > %d", t.Value, t.ScaleUpThreshold)
+	} else if t.Value < t.ScaleDownThreshold {
+		desired--
+		why = fmt.Sprintf("pending: %d < %d", t.Value, t.ScaleDownThreshold)
+	}
+	return desired, why
+}
+
+// proportionalRecommendation sizes the replicas as ceil(value / TargetPendingPerReplica), like the HPA
+// does for average value metrics.
+func proportionalRecommendation(rule internalTypes.ScalerParams, t internalTypes.Trigger) (desired int64, why string) {
+	why = fmt.Sprintf("pending: %d, target per replica: %d", t.Value, t.TargetPendingPerReplica)
+	if t.TargetPendingPerReplica <= 0 {
+		return int64(rule.MinReplicas), why
+	}
+	return int64(math.Ceil(float64(t.Value) / float64(t.TargetPendingPerReplica))), why
+}
+
+// throughputRecommendation sizes the replicas as ceil(IngressRate / TargetRatePerReplica), so that they
+// keep up with the messages coming in however large the backlog is. The replicas are held until the rate
+// is known.
+func throughputRecommendation(current int32, rule internalTypes.ScalerParams, t internalTypes.Trigger) (desired int64, why string) {
+	if !t.RateKnown {
+		return int64(current), ""
+	}
+	why = fmt.Sprintf("ingress rate: %.2f/s, target per replica: %.2f/s", t.IngressRate, t.TargetRatePerReplica)
+	if t.TargetRatePerReplica <= 0 {
+		return int64(rule.MinReplicas), why
+	}
+	return int64(math.Ceil(t.IngressRate / t.TargetRatePerReplica)), why
+}
+
+// clampReplicas clamps a recommendation to MinReplicas and MaxReplicas, limited is true when it had to.
+func clampReplicas(rule internalTypes.ScalerParams, recommended int64) (desired int32, limited bool) {
+	switch {
+	case recommended > int64(rule.MaxReplicas):
+		return rule.MaxReplicas, true
+	case recommended < int64(rule.MinReplicas):
+		return rule.MinReplicas, true
+	}
+	return int32(recommended), false
+}
+
+// newTargetObject returns an empty object for the target, typed when its kind is registered in the
+// client scheme and unstructured otherwise (e.g. Argo Rollouts), so any scalable resource can be addressed.
+func newTargetObject(k8s client.Client, target internalTypes.ScaleTarget) (client.Object, error) {
+	var obj client.Object
+	if typed, err := k8s.Scheme().New(target.GroupVersionKind); err == nil {
+		co, ok := typed.(client.Object)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a Kubernetes object", target.GroupVersionKind)
+		}
+		obj = co
+	} else {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(target.GroupVersionKind)
+		obj = u
+	}
+	obj.SetName(target.Name)
+	obj.SetNamespace(target.Namespace)
+	return obj, nil
 }
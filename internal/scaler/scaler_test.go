@@ -3,15 +3,19 @@ package scaler
 import (
 	"context"
 	"testing"
+	"time"
 
 	internalTypes "github.com/Av1shay/nats-scaler/internal/types"
 	testutils "github.com/Av1shay/nats-scaler/test/utils"
 	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
@@ -44,15 +48,22 @@ func TestRealScaler_ReconcileScale(t *testing.T) {
 	nn := types.NamespacedName{
 		Name: deploymentName, Namespace: namespace,
 	}
+	target := internalTypes.ScaleTarget{
+		GroupVersionKind: appsv1.SchemeGroupVersion.WithKind("Deployment"),
+		NamespacedName:   nn,
+	}
 
 	t.Run("scale up", func(t *testing.T) {
-		err := scaler.ReconcileScale(ctx, k8sClient, nn, internalTypes.ScalerParams{
-			ScaleUpThreshold:   10,
-			ScaleDownThreshold: 3,
-			MinReplicas:        1,
-			MaxReplicas:        5,
-		}, 20)
+		res, err := scaler.ReconcileScale(ctx, k8sClient, target, internalTypes.ScalerParams{
+			Triggers:    []internalTypes.Trigger{{Value: 20, ScaleUpThreshold: 10, ScaleDownThreshold: 3}},
+			MinReplicas: 1,
+			MaxReplicas: 5,
+		})
 		require.NoError(t, err)
+		require.Equal(t, int32(1), res.CurrentReplicas)
+		require.Equal(t, int32(2), res.DesiredReplicas)
+		require.True(t, res.Scaled)
+		require.False(t, res.LastScaleTime.IsZero())
 
 		var updated appsv1.Deployment
 		err = k8sClient.Get(ctx, nn, &updated)
@@ -67,13 +78,15 @@ func TestRealScaler_ReconcileScale(t *testing.T) {
 	})
 
 	t.Run("scale down", func(t *testing.T) {
-		err := scaler.ReconcileScale(ctx, k8sClient, nn, internalTypes.ScalerParams{
-			ScaleUpThreshold:   10,
-			ScaleDownThreshold: 3,
-			MinReplicas:        1,
-			MaxReplicas:        5,
-		}, 2)
+		res, err := scaler.ReconcileScale(ctx, k8sClient, target, internalTypes.ScalerParams{
+			Triggers:    []internalTypes.Trigger{{Value: 2, ScaleUpThreshold: 10, ScaleDownThreshold: 3}},
+			MinReplicas: 1,
+			MaxReplicas: 5,
+		})
 		require.NoError(t, err)
+		require.Equal(t, int32(2), res.CurrentReplicas)
+		require.Equal(t, int32(1), res.DesiredReplicas)
+		require.True(t, res.Scaled)
 
 		var updated appsv1.Deployment
 		err = k8sClient.Get(ctx, nn, &updated)
@@ -83,8 +96,612 @@ func TestRealScaler_ReconcileScale(t *testing.T) {
 
 		log := fakeLogger.Buff.String()
 		require.Equal(t, "Scaling down: 2 → 1 (pending: 2 < 3)", log)
+		fakeLogger.Buff.Reset()
+	})
+
+	t.Run("limited by min replicas", func(t *testing.T) {
+		res, err := scaler.ReconcileScale(ctx, k8sClient, target, internalTypes.ScalerParams{
+			Triggers:    []internalTypes.Trigger{{Value: 0, ScaleUpThreshold: 10, ScaleDownThreshold: 3}},
+			MinReplicas: 1,
+			MaxReplicas: 5,
+		})
+		require.NoError(t, err)
+		require.Equal(t, int32(1), res.CurrentReplicas)
+		require.Equal(t, int32(1), res.DesiredReplicas)
+		require.False(t, res.Scaled)
+		require.True(t, res.Limited)
+		require.Empty(t, fakeLogger.Buff.String())
+	})
+
+	t.Run("cooldown", func(t *testing.T) {
+		lastScale := time.Now().Add(-time.Minute)
+		res, err := NewScaler(WithCooldown(time.Hour)).ReconcileScale(ctx, k8sClient, target, internalTypes.ScalerParams{
+			Triggers:      []internalTypes.Trigger{{Value: 20, ScaleUpThreshold: 10, ScaleDownThreshold: 3}},
+			MinReplicas:   1,
+			MaxReplicas:   5,
+			LastScaleTime: lastScale,
+		})
+		require.NoError(t, err)
+		require.Equal(t, int32(2), res.DesiredReplicas)
+		require.True(t, res.CooldownActive)
+		require.False(t, res.Scaled)
+		require.Equal(t, lastScale, res.LastScaleTime)
+
+		var updated appsv1.Deployment
+		require.NoError(t, k8sClient.Get(ctx, nn, &updated))
+		require.Equal(t, int32(1), *updated.Spec.Replicas)
+		fakeLogger.Buff.Reset()
 	})
 
-	_, ok := lastScaleMap.Load(nn)
-	require.True(t, ok)
+}
+
+func TestRealScaler_ReconcileScale_CooldownAfterScale(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), logr.New(&testutils.FakeLogger{}))
+	scheme := runtime.NewScheme()
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	initialReplicas := int32(1)
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-deploy", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &initialReplicas},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(deploy).Build()
+	target := internalTypes.ScaleTarget{
+		GroupVersionKind: appsv1.SchemeGroupVersion.WithKind("Deployment"),
+		NamespacedName:   types.NamespacedName{Name: "my-deploy", Namespace: "default"},
+	}
+	// the second reconcile is triggered by the scale itself, before the status carries the last scale time
+	params := internalTypes.ScalerParams{
+		Triggers:    []internalTypes.Trigger{{Value: 20, ScaleUpThreshold: 10, ScaleDownThreshold: 3}},
+		MinReplicas: 1,
+		MaxReplicas: 5,
+	}
+	scaler := NewScaler(WithCooldown(time.Minute))
+
+	res, err := scaler.ReconcileScale(ctx, k8sClient, target, params)
+	require.NoError(t, err)
+	require.True(t, res.Scaled)
+	require.Equal(t, int32(2), res.DesiredReplicas)
+	scaledAt := res.LastScaleTime
+
+	res, err = scaler.ReconcileScale(ctx, k8sClient, target, params)
+	require.NoError(t, err)
+	require.False(t, res.Scaled)
+	require.True(t, res.CooldownActive)
+	require.Equal(t, scaledAt, res.LastScaleTime)
+
+	var updated appsv1.Deployment
+	require.NoError(t, k8sClient.Get(ctx, target.NamespacedName, &updated))
+	require.Equal(t, int32(2), *updated.Spec.Replicas)
+}
+
+func TestRealScaler_ReconcileScale_StatefulSet(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), logr.New(&testutils.FakeLogger{}))
+	scheme := runtime.NewScheme()
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	initialReplicas := int32(2)
+
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-sts", Namespace: "default"},
+		Spec:       appsv1.StatefulSetSpec{Replicas: &initialReplicas},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(sts).Build()
+
+	target := internalTypes.ScaleTarget{
+		GroupVersionKind: appsv1.SchemeGroupVersion.WithKind("StatefulSet"),
+		NamespacedName:   types.NamespacedName{Name: "my-sts", Namespace: "default"},
+	}
+	res, err := NewScaler(WithCooldown(0)).ReconcileScale(ctx, k8sClient, target, internalTypes.ScalerParams{
+		Triggers:    []internalTypes.Trigger{{Value: 20, ScaleUpThreshold: 10, ScaleDownThreshold: 3}},
+		MinReplicas: 1,
+		MaxReplicas: 5,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(3), res.DesiredReplicas)
+
+	var updated appsv1.StatefulSet
+	require.NoError(t, k8sClient.Get(ctx, target.NamespacedName, &updated))
+	require.Equal(t, int32(3), *updated.Spec.Replicas)
+
+	target.Name = "missing"
+	_, err = NewScaler().ReconcileScale(ctx, k8sClient, target, internalTypes.ScalerParams{})
+	require.True(t, apierrors.IsNotFound(err))
+}
+
+func TestRealScaler_ReconcileScale_Proportional(t *testing.T) {
+	fakeLogger := &testutils.FakeLogger{}
+	ctx := logr.NewContext(context.Background(), logr.New(fakeLogger))
+	scheme := runtime.NewScheme()
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	initialReplicas := int32(1)
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-deploy", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &initialReplicas},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(deploy).Build()
+	target := internalTypes.ScaleTarget{
+		GroupVersionKind: appsv1.SchemeGroupVersion.WithKind("Deployment"),
+		NamespacedName:   types.NamespacedName{Name: "my-deploy", Namespace: "default"},
+	}
+	params := internalTypes.ScalerParams{
+		Policy:      internalTypes.ScalingPolicyProportional,
+		MinReplicas: 1,
+		MaxReplicas: 10,
+		Triggers:    []internalTypes.Trigger{{TargetPendingPerReplica: 100}},
+	}
+
+	res, err := NewScaler(WithCooldown(0)).ReconcileScale(ctx, k8sClient, target, withValue(params, 350))
+	require.NoError(t, err)
+	require.Equal(t, int32(4), res.DesiredReplicas)
+	require.True(t, res.Scaled)
+	require.Equal(t, "Scaling up: 1 → 4 (pending: 350, target per replica: 100)", fakeLogger.Buff.String())
+
+	var updated appsv1.Deployment
+	require.NoError(t, k8sClient.Get(ctx, target.NamespacedName, &updated))
+	require.Equal(t, int32(4), *updated.Spec.Replicas)
+}
+
+func TestRealScaler_ReconcileScale_Stabilization(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), logr.New(&testutils.FakeLogger{}))
+	scheme := runtime.NewScheme()
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	initialReplicas := int32(1)
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-deploy", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &initialReplicas},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(deploy).Build()
+	target := internalTypes.ScaleTarget{
+		GroupVersionKind: appsv1.SchemeGroupVersion.WithKind("Deployment"),
+		NamespacedName:   types.NamespacedName{Name: "my-deploy", Namespace: "default"},
+	}
+	params := internalTypes.ScalerParams{
+		Policy:                       internalTypes.ScalingPolicyProportional,
+		MinReplicas:                  1,
+		MaxReplicas:                  10,
+		Triggers:                     []internalTypes.Trigger{{TargetPendingPerReplica: 100}},
+		ScaleDownStabilizationWindow: time.Hour,
+	}
+	scaler := NewScaler(WithCooldown(0))
+
+	// scaling up is immediate with no scale-up window
+	res, err := scaler.ReconcileScale(ctx, k8sClient, target, withValue(params, 500))
+	require.NoError(t, err)
+	require.Equal(t, int32(5), res.DesiredReplicas)
+	require.True(t, res.Scaled)
+	require.False(t, res.Stabilized)
+
+	// a drop in pending messages within the scale-down window keeps the replicas
+	res, err = scaler.ReconcileScale(ctx, k8sClient, target, withValue(params, 100))
+	require.NoError(t, err)
+	require.Equal(t, int32(5), res.CurrentReplicas)
+	require.Equal(t, int32(5), res.DesiredReplicas)
+	require.False(t, res.Scaled)
+	require.True(t, res.Stabilized)
+
+	// a scale-up window holds back a burst until it has lasted the whole window
+	params.ScaleUpStabilizationWindow = time.Hour
+	res, err = scaler.ReconcileScale(ctx, k8sClient, target, withValue(params, 900))
+	require.NoError(t, err)
+	require.Equal(t, int32(5), res.DesiredReplicas)
+	require.True(t, res.Stabilized)
+}
+
+func TestRealScaler_ReconcileScale_ScaleToZero(t *testing.T) {
+	fakeLogger := &testutils.FakeLogger{}
+	ctx := logr.NewContext(context.Background(), logr.New(fakeLogger))
+	scheme := runtime.NewScheme()
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	initialReplicas := int32(0)
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-deploy", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &initialReplicas},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(deploy).Build()
+	target := internalTypes.ScaleTarget{
+		GroupVersionKind: appsv1.SchemeGroupVersion.WithKind("Deployment"),
+		NamespacedName:   types.NamespacedName{Name: "my-deploy", Namespace: "default"},
+	}
+	stz := &internalTypes.ScaleToZeroParams{ActivationThreshold: 5, ActivationReplicas: 3, IdleAfter: time.Minute}
+	params := internalTypes.ScalerParams{
+		MinReplicas: 0,
+		MaxReplicas: 5,
+		Triggers:    []internalTypes.Trigger{{ScaleUpThreshold: 100, ScaleDownThreshold: 10}},
+		ScaleToZero: stz,
+		// activation is not held back by the cooldown
+		LastScaleTime: time.Now(),
+	}
+	scaler := NewScaler()
+
+	// below the activation threshold the target stays at zero
+	res, err := scaler.ReconcileScale(ctx, k8sClient, target, withValue(params, 4))
+	require.NoError(t, err)
+	require.Equal(t, int32(0), res.DesiredReplicas)
+	require.False(t, res.Scaled)
+	require.False(t, res.Activated)
+
+	res, err = scaler.ReconcileScale(ctx, k8sClient, target, withValue(params, 5))
+	require.NoError(t, err)
+	require.Equal(t, int32(3), res.DesiredReplicas)
+	require.True(t, res.Scaled)
+	require.True(t, res.Activated)
+	require.Equal(t, "Scaling up: 0 → 3 (activation: pending 5 >= 5)", fakeLogger.Buff.String())
+	fakeLogger.Buff.Reset()
+
+	// with no pending messages the step policy stops at one replica until the consumer is idle
+	params.LastScaleTime = time.Time{}
+	scaler.lastScaled[target] = time.Now().Add(-time.Hour) // past the cooldown of the activation
+	var scale autoscalingv1.Scale
+	require.NoError(t, k8sClient.SubResource("scale").Get(ctx, deploy, &scale))
+	scale.Spec.Replicas = 1
+	require.NoError(t, k8sClient.SubResource("scale").Update(ctx, deploy, client.WithSubResourceBody(&scale)))
+	res, err = scaler.ReconcileScale(ctx, k8sClient, target, withValue(params, 0))
+	require.NoError(t, err)
+	require.Equal(t, int32(1), res.DesiredReplicas)
+	require.False(t, res.Scaled)
+
+	stz.IdleSince = time.Now().Add(-30 * time.Second)
+	res, err = scaler.ReconcileScale(ctx, k8sClient, target, withValue(params, 0))
+	require.NoError(t, err)
+	require.Equal(t, int32(1), res.DesiredReplicas)
+	require.False(t, res.Idle)
+
+	stz.IdleSince = time.Now().Add(-2 * time.Minute)
+	res, err = scaler.ReconcileScale(ctx, k8sClient, target, withValue(params, 0))
+	require.NoError(t, err)
+	require.Equal(t, int32(0), res.DesiredReplicas)
+	require.True(t, res.Scaled)
+	require.True(t, res.Idle)
+
+	var updated appsv1.Deployment
+	require.NoError(t, k8sClient.Get(ctx, target.NamespacedName, &updated))
+	require.Equal(t, int32(0), *updated.Spec.Replicas)
+}
+
+func TestRealScaler_ReconcileScale_ActivateOnPending(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	initialReplicas := int32(0)
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-deploy", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &initialReplicas},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(deploy).Build()
+	target := internalTypes.ScaleTarget{
+		GroupVersionKind: appsv1.SchemeGroupVersion.WithKind("Deployment"),
+		NamespacedName:   types.NamespacedName{Name: "my-deploy", Namespace: "default"},
+	}
+	// an ackPending trigger stays at zero while no consumer runs, the backlog still wakes the target up
+	params := internalTypes.ScalerParams{
+		MinReplicas: 0,
+		MaxReplicas: 5,
+		Triggers:    []internalTypes.Trigger{{Name: "ack-pending", Value: 0, Pending: 500, ScaleUpThreshold: 100, ScaleDownThreshold: 10}},
+		ScaleToZero: &internalTypes.ScaleToZeroParams{ActivationThreshold: 5, ActivationReplicas: 2, IdleAfter: time.Minute},
+	}
+	res, err := NewScaler().ReconcileScale(ctx, k8sClient, target, params)
+	require.NoError(t, err)
+	require.True(t, res.Activated)
+	require.Equal(t, "ack-pending", res.ActiveTrigger)
+	require.Equal(t, int32(2), res.DesiredReplicas)
+
+	var updated appsv1.Deployment
+	require.NoError(t, k8sClient.Get(ctx, target.NamespacedName, &updated))
+	require.Equal(t, int32(2), *updated.Spec.Replicas)
+}
+
+func TestRealScaler_ReconcileScale_MultipleTriggers(t *testing.T) {
+	fakeLogger := &testutils.FakeLogger{}
+	ctx := logr.NewContext(context.Background(), logr.New(fakeLogger))
+	scheme := runtime.NewScheme()
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	initialReplicas := int32(2)
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-deploy", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &initialReplicas},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(deploy).Build()
+	target := internalTypes.ScaleTarget{
+		GroupVersionKind: appsv1.SchemeGroupVersion.WithKind("Deployment"),
+		NamespacedName:   types.NamespacedName{Name: "my-deploy", Namespace: "default"},
+	}
+	params := internalTypes.ScalerParams{
+		Policy:      internalTypes.ScalingPolicyProportional,
+		MinReplicas: 1,
+		MaxReplicas: 10,
+		Triggers: []internalTypes.Trigger{
+			{Name: "orders", Value: 250, TargetPendingPerReplica: 100},
+			{Name: "payments", Value: 40, TargetPendingPerReplica: 10},
+			{Name: "refunds", Value: 0, TargetPendingPerReplica: 10},
+		},
+	}
+
+	res, err := NewScaler(WithCooldown(0)).ReconcileScale(ctx, k8sClient, target, params)
+	require.NoError(t, err)
+	require.Equal(t, int32(4), res.DesiredReplicas)
+	require.Equal(t, "payments", res.ActiveTrigger)
+	require.Equal(t, []internalTypes.TriggerResult{
+		{Name: "orders", Value: 250, DesiredReplicas: 3},
+		{Name: "payments", Value: 40, DesiredReplicas: 4},
+		{Name: "refunds", Value: 0, DesiredReplicas: 1},
+	}, res.Triggers)
+	require.Equal(t, "Scaling up: 2 → 4 (payments: pending: 40, target per replica: 10)", fakeLogger.Buff.String())
+}
+
+func TestRealScaler_ReconcileScale_Fallback(t *testing.T) {
+	fakeLogger := &testutils.FakeLogger{}
+	ctx := logr.NewContext(context.Background(), logr.New(fakeLogger))
+	scheme := runtime.NewScheme()
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	initialReplicas := int32(2)
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-deploy", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &initialReplicas},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(deploy).Build()
+	target := internalTypes.ScaleTarget{
+		GroupVersionKind: appsv1.SchemeGroupVersion.WithKind("Deployment"),
+		NamespacedName:   types.NamespacedName{Name: "my-deploy", Namespace: "default"},
+	}
+	fallback := int32(20)
+	params := internalTypes.ScalerParams{
+		MinReplicas:                1,
+		MaxReplicas:                8,
+		FallbackReplicas:           &fallback,
+		ScaleUpStabilizationWindow: time.Hour,
+		// the fallback isn't held back by the cooldown
+		LastScaleTime: time.Now(),
+	}
+
+	res, err := NewScaler().ReconcileScale(ctx, k8sClient, target, params)
+	require.NoError(t, err)
+	require.True(t, res.Fallback)
+	require.True(t, res.Scaled)
+	require.True(t, res.Limited)
+	require.Equal(t, int32(8), res.DesiredReplicas)
+	require.Equal(t, "Scaling up: 2 → 8 (metrics unavailable, fallback)", fakeLogger.Buff.String())
+
+	res, err = NewScaler().ReconcileScale(ctx, k8sClient, target, params)
+	require.NoError(t, err)
+	require.True(t, res.Fallback)
+	require.False(t, res.Scaled)
+	require.Equal(t, int32(8), res.CurrentReplicas)
+}
+
+func TestRealScaler_SetScale(t *testing.T) {
+	fakeLogger := &testutils.FakeLogger{}
+	ctx := logr.NewContext(context.Background(), logr.New(fakeLogger))
+	scheme := runtime.NewScheme()
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	initialReplicas := int32(7)
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-deploy", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &initialReplicas},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(deploy).Build()
+	target := internalTypes.ScaleTarget{
+		GroupVersionKind: appsv1.SchemeGroupVersion.WithKind("Deployment"),
+		NamespacedName:   types.NamespacedName{Name: "my-deploy", Namespace: "default"},
+	}
+
+	scaler := NewScaler(WithCooldown(time.Hour))
+	res, err := scaler.SetScale(ctx, k8sClient, target, 3, "restoring the replicas of a deleted rule")
+	require.NoError(t, err)
+	require.True(t, res.Scaled)
+	require.Equal(t, int32(7), res.CurrentReplicas)
+	require.Equal(t, int32(3), res.DesiredReplicas)
+	require.Equal(t, "Scaling down: 7 → 3 (restoring the replicas of a deleted rule)", fakeLogger.Buff.String())
+
+	var updated appsv1.Deployment
+	require.NoError(t, k8sClient.Get(ctx, target.NamespacedName, &updated))
+	require.Equal(t, int32(3), *updated.Spec.Replicas)
+
+	res, err = scaler.SetScale(ctx, k8sClient, target, 3, "restoring the replicas of a deleted rule")
+	require.NoError(t, err)
+	require.False(t, res.Scaled)
+
+	target.Name = "missing"
+	_, err = scaler.SetScale(ctx, k8sClient, target, 3, "restoring the replicas of a deleted rule")
+	require.True(t, apierrors.IsNotFound(err))
+}
+
+func TestRealScaler_Predict(t *testing.T) {
+	target := internalTypes.ScaleTarget{
+		GroupVersionKind: appsv1.SchemeGroupVersion.WithKind("Deployment"),
+		NamespacedName:   types.NamespacedName{Name: "my-deploy", Namespace: "default"},
+	}
+	start := time.Date(2025, 6, 10, 22, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		params internalTypes.PredictionParams
+		values []int
+		want   int
+	}{
+		{
+			name:   "single sample",
+			params: internalTypes.PredictionParams{Samples: 5, LookAhead: time.Minute},
+			values: []int{100},
+			want:   100,
+		},
+		{
+			// +10 per 10s poll, projected a minute ahead
+			name:   "linear growth",
+			params: internalTypes.PredictionParams{Model: internalTypes.PredictionModelLinear, Samples: 5, LookAhead: time.Minute},
+			values: []int{100, 110, 120, 130, 140},
+			want:   200,
+		},
+		{
+			name:   "fitted over the last samples only",
+			params: internalTypes.PredictionParams{Samples: 3, LookAhead: 20 * time.Second},
+			values: []int{500, 0, 100, 110, 120},
+			want:   140,
+		},
+		{
+			name:   "draining backlog",
+			params: internalTypes.PredictionParams{Samples: 5, LookAhead: time.Minute},
+			values: []int{40, 30, 20, 10, 0},
+			want:   0,
+		},
+		{
+			// doubling every poll
+			name:   "exponential growth",
+			params: internalTypes.PredictionParams{Model: internalTypes.PredictionModelExponential, Samples: 5, LookAhead: 20 * time.Second},
+			values: []int{15, 31, 63, 127, 255},
+			want:   1023,
+		},
+		{
+			name:   "samples past max age dropped",
+			params: internalTypes.PredictionParams{Samples: 5, LookAhead: time.Minute, MaxAge: 5 * time.Second},
+			values: []int{100, 200, 300},
+			want:   300,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scaler := NewScaler()
+			var got int
+			for i, v := range tt.values {
+				trigger := internalTypes.Trigger{Name: "orders", Value: v}
+				got = scaler.predict(target, trigger, start.Add(time.Duration(i)*10*time.Second), tt.params)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRealScaler_Predict_Interval(t *testing.T) {
+	target := internalTypes.ScaleTarget{
+		GroupVersionKind: appsv1.SchemeGroupVersion.WithKind("Deployment"),
+		NamespacedName:   types.NamespacedName{Name: "my-deploy", Namespace: "default"},
+	}
+	key := sampleKey{target: target, trigger: "orders"}
+	start := time.Date(2025, 6, 10, 22, 0, 0, 0, time.UTC)
+	params := internalTypes.PredictionParams{Samples: 5, LookAhead: time.Minute, Interval: 10 * time.Second}
+	scaler := NewScaler()
+
+	scaler.predict(target, internalTypes.Trigger{Name: "orders", Value: 100}, start, params)
+	require.Equal(t, 170, scaler.predict(target, internalTypes.Trigger{Name: "orders", Value: 110}, start.Add(10*time.Second), params))
+
+	// a reconcile triggered by a watch right after the poll is projected from the recorded samples only
+	require.Equal(t, 171, scaler.predict(target, internalTypes.Trigger{Name: "orders", Value: 150}, start.Add(11*time.Second), params))
+	require.Len(t, scaler.samples[key], 2)
+
+	require.Equal(t, 180, scaler.predict(target, internalTypes.Trigger{Name: "orders", Value: 120}, start.Add(20*time.Second), params))
+	require.Len(t, scaler.samples[key], 3)
+}
+
+func TestRealScaler_ReconcileScale_Prediction(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	initialReplicas := int32(2)
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-deploy", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &initialReplicas},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(deploy).Build()
+	target := internalTypes.ScaleTarget{
+		GroupVersionKind: appsv1.SchemeGroupVersion.WithKind("Deployment"),
+		NamespacedName:   types.NamespacedName{Name: "my-deploy", Namespace: "default"},
+	}
+	prediction := internalTypes.PredictionParams{Samples: 5, LookAhead: time.Minute}
+	params := internalTypes.ScalerParams{
+		Policy:      internalTypes.ScalingPolicyProportional,
+		MinReplicas: 1,
+		MaxReplicas: 10,
+		Prediction:  &prediction,
+		Triggers:    []internalTypes.Trigger{{Name: "orders", TargetPendingPerReplica: 100}},
+	}
+
+	scaler := NewScaler(WithCooldown(0))
+	// the backlog grew by 50 every 10 seconds
+	now := time.Now()
+	for i, v := range []int{0, 50, 100} {
+		scaler.predict(target, internalTypes.Trigger{Name: "orders", Value: v}, now.Add(time.Duration(i-3)*10*time.Second), prediction)
+	}
+
+	res, err := scaler.ReconcileScale(ctx, k8sClient, target, withValue(params, 150))
+	require.NoError(t, err)
+	require.True(t, res.Scaled)
+	// 150 pending only need 2 replicas, 450 are expected a minute from now
+	require.Equal(t, int32(5), res.DesiredReplicas)
+	require.Equal(t, 150, res.Triggers[0].Value)
+	require.InDelta(t, 450, res.Triggers[0].Predicted, 1)
+}
+
+func TestProportionalRecommendation(t *testing.T) {
+	params := internalTypes.ScalerParams{
+		Policy:      internalTypes.ScalingPolicyProportional,
+		MinReplicas: 1,
+		MaxReplicas: 10,
+	}
+
+	tests := []struct {
+		name        string
+		pending     int
+		wantDesired int32
+		wantLimited bool
+	}{
+		{name: "exact multiple", pending: 300, wantDesired: 3},
+		{name: "rounds up", pending: 301, wantDesired: 4},
+		{name: "clamped to max", pending: 100000, wantDesired: 10, wantLimited: true},
+		{name: "clamped to min", pending: 0, wantDesired: 1, wantLimited: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, _ := proportionalRecommendation(params, internalTypes.Trigger{Value: tt.pending, TargetPendingPerReplica: 100})
+			desired, limited := clampReplicas(params, raw)
+			require.Equal(t, tt.wantDesired, desired)
+			require.Equal(t, tt.wantLimited, limited)
+		})
+	}
+}
+
+func TestThroughputRecommendation(t *testing.T) {
+	params := internalTypes.ScalerParams{
+		Policy:      internalTypes.ScalingPolicyThroughput,
+		MinReplicas: 1,
+		MaxReplicas: 10,
+	}
+
+	tests := []struct {
+		name        string
+		trigger     internalTypes.Trigger
+		wantDesired int32
+		wantLimited bool
+	}{
+		{name: "exact multiple", trigger: internalTypes.Trigger{IngressRate: 150, RateKnown: true}, wantDesired: 3},
+		{name: "rounds up", trigger: internalTypes.Trigger{IngressRate: 150.5, RateKnown: true}, wantDesired: 4},
+		{name: "large backlog, no ingress", trigger: internalTypes.Trigger{Value: 100000, RateKnown: true}, wantDesired: 1, wantLimited: true},
+		{name: "clamped to max", trigger: internalTypes.Trigger{IngressRate: 5000, RateKnown: true}, wantDesired: 10, wantLimited: true},
+		{name: "unknown rate holds", trigger: internalTypes.Trigger{IngressRate: 5000}, wantDesired: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.trigger.TargetRatePerReplica = 50
+			raw, _ := throughputRecommendation(5, params, tt.trigger)
+			desired, limited := clampReplicas(params, raw)
+			require.Equal(t, tt.wantDesired, desired)
+			require.Equal(t, tt.wantLimited, limited)
+		})
+	}
+}
+
+// withValue returns a copy of params with the value and pending messages of its triggers set to value.
+func withValue(params internalTypes.ScalerParams, value int) internalTypes.ScalerParams {
+	params.Triggers = append([]internalTypes.Trigger(nil), params.Triggers...)
+	for i := range params.Triggers {
+		params.Triggers[i].Value = value
+		params.Triggers[i].Pending = value
+	}
+	return params
 }
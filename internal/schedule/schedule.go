@@ -0,0 +1,53 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	// time zones are resolved from the embedded database, the manager image has none
+	_ "time/tzdata"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Window is a recurring time window, opening on a cron schedule and staying open for a fixed duration.
+type Window struct {
+	start    *cron.SpecSchedule
+	duration time.Duration
+}
+
+// Parse parses a window opening on the standard 5-field cron expression start, or a descriptor such as
+// @daily, evaluated in timeZone (UTC when empty).
+func Parse(start, timeZone string, duration time.Duration) (*Window, error) {
+	if duration <= 0 {
+		return nil, errors.New("duration must be positive")
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", timeZone, err)
+	}
+	sched, err := cron.ParseStandard(start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", start, err)
+	}
+	spec, ok := sched.(*cron.SpecSchedule)
+	if !ok {
+		// @every has no fixed start times to open the window on
+		return nil, fmt.Errorf("invalid cron expression %q: intervals are not supported", start)
+	}
+	if spec.Location != time.Local {
+		return nil, fmt.Errorf("invalid cron expression %q: set the time zone separately", start)
+	}
+	spec.Location = loc
+	return &Window{start: spec, duration: duration}, nil
+}
+
+// Active reports whether the window is open at now, i.e. it opened within the last duration.
+func (w *Window) Active(now time.Time) bool {
+	return !w.start.Next(now.Add(-w.duration)).After(now)
+}
+
+// NextStart returns the next time the window opens after now.
+func (w *Window) NextStart(now time.Time) time.Time {
+	return w.start.Next(now)
+}
@@ -0,0 +1,56 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindow_Active(t *testing.T) {
+	// nightly batch load from 22:00 to 01:00 Berlin time
+	w, err := Parse("0 22 * * *", "Europe/Berlin", 3*time.Hour)
+	require.NoError(t, err)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{"before", time.Date(2025, 6, 10, 21, 59, 59, 0, berlin), false},
+		{"opening", time.Date(2025, 6, 10, 22, 0, 0, 0, berlin), true},
+		{"past midnight", time.Date(2025, 6, 11, 0, 30, 0, 0, berlin), true},
+		{"closing", time.Date(2025, 6, 11, 1, 0, 0, 0, berlin), false},
+		{"in UTC", time.Date(2025, 6, 10, 20, 30, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, w.Active(tt.now))
+		})
+	}
+
+	next := w.NextStart(time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC))
+	require.True(t, next.Equal(time.Date(2025, 6, 10, 20, 0, 0, 0, time.UTC)))
+}
+
+func TestParse(t *testing.T) {
+	_, err := Parse("@daily", "", time.Hour)
+	require.NoError(t, err)
+
+	_, err = Parse("0 22 * *", "", time.Hour)
+	require.ErrorContains(t, err, "invalid cron expression")
+
+	_, err = Parse("@every 1h", "", time.Hour)
+	require.ErrorContains(t, err, "intervals are not supported")
+
+	_, err = Parse("CRON_TZ=UTC 0 22 * * *", "", time.Hour)
+	require.ErrorContains(t, err, "set the time zone separately")
+
+	_, err = Parse("0 22 * * *", "Mars/Olympus", time.Hour)
+	require.ErrorContains(t, err, "invalid time zone")
+
+	_, err = Parse("0 22 * * *", "", 0)
+	require.ErrorContains(t, err, "duration must be positive")
+}
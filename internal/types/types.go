@@ -1,8 +1,139 @@
 package types
 
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ScaleTarget identifies a workload exposing the scale subresource.
+type ScaleTarget struct {
+	GroupVersionKind schema.GroupVersionKind
+	types.NamespacedName
+}
+
+// ScalingPolicy selects how the scaler computes the desired replica count.
+type ScalingPolicy string
+
+const (
+	// ScalingPolicyStep moves the replicas by one when a threshold is crossed.
+	ScalingPolicyStep ScalingPolicy = "step"
+	// ScalingPolicyProportional sizes the replicas as ceil(pending / TargetPendingPerReplica).
+	ScalingPolicyProportional ScalingPolicy = "proportional"
+	// ScalingPolicyThroughput sizes the replicas as ceil(IngressRate / TargetRatePerReplica).
+	ScalingPolicyThroughput ScalingPolicy = "throughput"
+)
+
+// PredictionModel selects how recent trigger values are projected forward.
+type PredictionModel string
+
+const (
+	// PredictionModelLinear fits a least squares line through the samples.
+	PredictionModelLinear PredictionModel = "linear"
+	// PredictionModelExponential fits a least squares line through the logarithm of the samples.
+	PredictionModelExponential PredictionModel = "exponential"
+)
+
+// PredictionParams configures scaling on the trigger values projected from their recent growth.
+type PredictionParams struct {
+	Model PredictionModel
+	// Samples is the number of recent values the model is fitted over.
+	Samples int
+	// LookAhead is how far ahead of now the values are projected.
+	LookAhead time.Duration
+	// MaxAge drops older samples, so that a gap in the history (e.g. while suspended) doesn't skew the fit.
+	MaxAge time.Duration
+	// Interval is the shortest time between two samples, the poll interval. Values read sooner, e.g. by
+	// reconciles triggered by a watch, aren't recorded, samples taken almost together would skew the fit.
+	Interval time.Duration
+}
+
+// Trigger is a metric value the scaler computes a replica recommendation for.
+type Trigger struct {
+	Name string
+	// Value is the metric the trigger scales on, Pending the pending messages of the consumer, which wake
+	// a target at zero replicas up whatever the metric.
+	Value                   int
+	Pending                 int
+	ScaleUpThreshold        int
+	ScaleDownThreshold      int
+	TargetPendingPerReplica int
+	// IngressRate is the rate messages are appended to the stream in messages per second, only known
+	// (RateKnown) from the second lookup of the consumer on.
+	IngressRate          float64
+	RateKnown            bool
+	TargetRatePerReplica float64
+}
+
 type ScalerParams struct {
-	MinReplicas        int32
-	MaxReplicas        int32
-	ScaleUpThreshold   int
-	ScaleDownThreshold int
+	Policy      ScalingPolicy
+	MinReplicas int32
+	MaxReplicas int32
+	// Triggers are scaled on together, the highest recommendation wins.
+	Triggers []Trigger
+	// ScaleUpStabilizationWindow and ScaleDownStabilizationWindow are the look-back windows over
+	// which recommendations are considered before scaling in each direction.
+	ScaleUpStabilizationWindow   time.Duration
+	ScaleDownStabilizationWindow time.Duration
+	// ScaleToZero enables scaling to and from zero replicas, nil keeps MinReplicas as a hard floor.
+	ScaleToZero *ScaleToZeroParams
+	// Prediction, when set, makes each trigger recommend replicas for the higher of its value and its
+	// projected value, so the target is scaled up while the backlog grows rather than once it is large.
+	Prediction *PredictionParams
+	// FallbackReplicas, when set, replaces the trigger recommendations while the metrics are unavailable.
+	// The target is scaled to it right away, without stabilization or cooldown.
+	FallbackReplicas *int32
+	// LastScaleTime is the last time the target was scaled, as persisted in the ScalingRule status,
+	// so the cooldown survives manager restarts and leader failover.
+	LastScaleTime time.Time
+}
+
+// ScaleToZeroParams configures activation from and scaling to zero replicas.
+type ScaleToZeroParams struct {
+	// ActivationThreshold is the pending count that wakes the target up from zero replicas.
+	ActivationThreshold int
+	// ActivationReplicas is the replica count the target is woken up to.
+	ActivationReplicas int32
+	// IdleAfter is how long the consumer must be idle before the target is scaled to zero.
+	IdleAfter time.Duration
+	// IdleSince is the time since which the consumer has been idle, zero while it has work.
+	IdleSince time.Time
+}
+
+// TriggerResult is the replica recommendation of a single trigger.
+type TriggerResult struct {
+	Name  string
+	Value int
+	// Predicted is the projected value, zero without prediction.
+	Predicted       int
+	DesiredReplicas int32
+}
+
+// ScaleResult describes the outcome of a single ReconcileScale call.
+type ScaleResult struct {
+	// TargetUID is the UID of the scaled workload, as reported by its scale subresource.
+	TargetUID       types.UID
+	CurrentReplicas int32
+	DesiredReplicas int32
+	// Scaled is true when the target replica count was changed by this call.
+	Scaled bool
+	// CooldownActive is true when a scale was needed but skipped due to cooldown.
+	CooldownActive bool
+	// Triggers holds the recommendation of each trigger, before clamping and stabilization.
+	Triggers []TriggerResult
+	// ActiveTrigger is the name of the trigger the desired replicas were computed from.
+	ActiveTrigger string
+	// Limited is true when the recommendation was clamped to MinReplicas or MaxReplicas.
+	Limited bool
+	// Activated is true when the target was recommended to wake up from zero replicas.
+	Activated bool
+	// Idle is true when the target was recommended to scale to zero after the consumer was idle.
+	Idle bool
+	// Stabilized is true when a stabilization window held back the recommendation.
+	Stabilized bool
+	// Fallback is true when the desired replicas are the fallback replicas rather than a recommendation.
+	Fallback bool
+	// LastScaleTime is the last time the target was scaled, zero if unknown.
+	LastScaleTime time.Time
 }
@@ -0,0 +1,208 @@
+// Package validation holds the validation of the ScalingRule spec shared by the admission webhook and the
+// controller.
+package validation
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	scalingv1 "github.com/Av1shay/nats-scaler/api/v1"
+	"github.com/Av1shay/nats-scaler/internal/schedule"
+)
+
+// ValidateScalingRuleSpec returns the errors of the spec, reported under specPath. It is run by the admission
+// webhook, and by the controller for clusters running with ENABLE_WEBHOOKS=false.
+func ValidateScalingRuleSpec(spec scalingv1.ScalingRuleSpec, specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	switch {
+	case spec.ScaleTargetRef == nil && spec.DeploymentName == "":
+		allErrs = append(allErrs, field.Required(specPath.Child("scaleTargetRef"), "one of scaleTargetRef or deploymentName is required"))
+	case spec.ScaleTargetRef != nil && spec.DeploymentName != "" &&
+		(spec.ScaleTarget().Kind != "Deployment" || spec.ScaleTargetRef.Name != spec.DeploymentName):
+		allErrs = append(allErrs, field.Invalid(specPath.Child("deploymentName"), spec.DeploymentName,
+			"must match scaleTargetRef, prefer setting scaleTargetRef only"))
+	case spec.ScaleTargetRef != nil:
+		if _, err := schema.ParseGroupVersion(spec.ScaleTargetRef.APIVersion); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("scaleTargetRef", "apiVersion"), spec.ScaleTargetRef.APIVersion, err.Error()))
+		}
+	}
+	if spec.MinReplicas > spec.MaxReplicas {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("minReplicas"), spec.MinReplicas,
+			fmt.Sprintf("must be less than or equal to maxReplicas (%d)", spec.MaxReplicas)))
+	}
+	if spec.ScaleToZero != nil {
+		if spec.MinReplicas != 0 {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("minReplicas"), spec.MinReplicas,
+				"must be 0 when scaleToZero is set"))
+		}
+		if spec.ScaleToZero.ActivationReplicas > spec.MaxReplicas {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("scaleToZero", "activationReplicas"), spec.ScaleToZero.ActivationReplicas,
+				fmt.Sprintf("must be less than or equal to maxReplicas (%d)", spec.MaxReplicas)))
+		}
+	}
+	if fb := spec.Fallback; fb != nil && fb.Action == scalingv1.FallbackActionReplicas && fb.Replicas == nil {
+		allErrs = append(allErrs, field.Required(specPath.Child("fallback", "replicas"), "required by the replicas fallback action"))
+	}
+	switch {
+	case len(spec.Triggers) == 0:
+		if spec.StreamName == "" {
+			allErrs = append(allErrs, field.Required(specPath.Child("streamName"), "required unless triggers is set"))
+		}
+		if spec.ConsumerName == "" {
+			allErrs = append(allErrs, field.Required(specPath.Child("consumerName"), "required unless triggers is set"))
+		}
+	case spec.StreamName != "" || spec.ConsumerName != "":
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("triggers"),
+			"must not be set together with streamName and consumerName"))
+	}
+	// settings a trigger inherits are reported on the trigger, as that's where they can be overridden
+	for i, t := range spec.EffectiveTriggers() {
+		triggerPath := specPath
+		if len(spec.Triggers) > 0 {
+			triggerPath = specPath.Child("triggers").Index(i)
+		}
+		allErrs = append(allErrs, validateTrigger(t, spec.ScalingPolicy, triggerPath)...)
+	}
+	if spec.PollIntervalSeconds < 1 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("pollIntervalSeconds"), spec.PollIntervalSeconds,
+			"must be at least 1"))
+	}
+
+	switch {
+	case spec.NatsConnectionRef != nil:
+		if spec.NatsMonitoringURL != "" {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("natsMonitoringURL"), "must not be set together with natsConnectionRef"))
+		}
+		if len(spec.NatsMonitoringURLs) > 0 {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("natsMonitoringURLs"), "must not be set together with natsConnectionRef"))
+		}
+		if spec.NatsURL != "" {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("natsURL"), "must not be set together with natsConnectionRef"))
+		}
+		if spec.NatsAuthSecretRef != nil {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("natsAuthSecretRef"), "must not be set together with natsConnectionRef"))
+		}
+	case spec.NatsBackend == scalingv1.NatsBackendJetStream:
+		// the NATS client takes a comma separated list of servers
+		for _, u := range strings.Split(spec.NatsURL, ",") {
+			allErrs = append(allErrs, validateURL(specPath.Child("natsURL"), strings.TrimSpace(u), "nats", "tls")...)
+		}
+	default:
+		if spec.NatsMonitoringURL != "" || len(spec.NatsMonitoringURLs) == 0 {
+			allErrs = append(allErrs, validateURL(specPath.Child("natsMonitoringURL"), spec.NatsMonitoringURL, "http", "https")...)
+		}
+		for i, u := range spec.NatsMonitoringURLs {
+			allErrs = append(allErrs, validateURL(specPath.Child("natsMonitoringURLs").Index(i), u, "http", "https")...)
+		}
+	}
+
+	// the overrides of the windows are only checked on an otherwise valid rule, so that its errors aren't
+	// reported once per window
+	valid := len(allErrs) == 0
+	for i, w := range spec.Schedules {
+		windowErrs := validateScheduleWindow(w, spec, specPath.Child("schedules").Index(i))
+		if len(windowErrs) == 0 && valid {
+			windowErrs = validateScheduleOverrides(w, spec, specPath)
+		}
+		allErrs = append(allErrs, windowErrs...)
+	}
+
+	return allErrs
+}
+
+func validateScheduleWindow(w scalingv1.ScheduleWindow, spec scalingv1.ScalingRuleSpec, windowPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if _, err := schedule.Parse(w.Start, w.TimeZone, time.Duration(w.DurationSeconds)*time.Second); err != nil {
+		allErrs = append(allErrs, field.Invalid(windowPath, w.Start, err.Error()))
+	}
+	minReplicas, maxReplicas := spec.MinReplicas, spec.MaxReplicas
+	if w.MinReplicas != nil {
+		minReplicas = *w.MinReplicas
+	}
+	if w.MaxReplicas != nil {
+		maxReplicas = *w.MaxReplicas
+	}
+	if minReplicas > maxReplicas {
+		allErrs = append(allErrs, field.Invalid(windowPath.Child("minReplicas"), minReplicas,
+			fmt.Sprintf("must be less than or equal to maxReplicas (%d) while the window is open", maxReplicas)))
+	}
+	switch {
+	case w.ScaleUpThreshold == nil && w.ScaleDownThreshold != nil:
+		allErrs = append(allErrs, field.Required(windowPath.Child("scaleUpThreshold"), "required together with scaleDownThreshold"))
+	case w.ScaleUpThreshold != nil && w.ScaleDownThreshold == nil:
+		allErrs = append(allErrs, field.Required(windowPath.Child("scaleDownThreshold"), "required together with scaleUpThreshold"))
+	case w.ScaleUpThreshold != nil && *w.ScaleDownThreshold >= *w.ScaleUpThreshold:
+		allErrs = append(allErrs, field.Invalid(windowPath.Child("scaleDownThreshold"), *w.ScaleDownThreshold,
+			fmt.Sprintf("must be less than scaleUpThreshold (%d)", *w.ScaleUpThreshold)))
+	}
+	return allErrs
+}
+
+// validateScheduleOverrides validates the rule as it is while the window is open, e.g. a window must not
+// lower maxReplicas below the activationReplicas of the rule.
+func validateScheduleOverrides(w scalingv1.ScheduleWindow, spec scalingv1.ScalingRuleSpec, specPath *field.Path) field.ErrorList {
+	overridden := spec.DeepCopy()
+	overridden.Schedules = nil
+	overridden.ApplySchedule(&w)
+	allErrs := ValidateScalingRuleSpec(*overridden, specPath)
+	for _, err := range allErrs {
+		err.Detail = fmt.Sprintf("%s, while schedule %s is open", err.Detail, w.Name)
+	}
+	return allErrs
+}
+
+func validateTrigger(t scalingv1.ScalingTrigger, policy scalingv1.ScalingPolicy, triggerPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if t.Metric == scalingv1.ScalingMetricWeighted {
+		w := t.MetricWeights
+		if w == nil || w.Pending+w.AckPending+w.Redelivered+w.Waiting == 0 {
+			allErrs = append(allErrs, field.Required(triggerPath.Child("metricWeights"),
+				"at least one non-zero weight is required by the weighted metric"))
+		}
+	}
+	switch policy {
+	case scalingv1.ScalingPolicyProportional:
+		if t.TargetPendingPerReplica < 1 {
+			allErrs = append(allErrs, field.Required(triggerPath.Child("targetPendingPerReplica"),
+				"required by the proportional scalingPolicy"))
+		}
+	case scalingv1.ScalingPolicyThroughput:
+		switch {
+		case t.TargetRatePerReplica == nil:
+			allErrs = append(allErrs, field.Required(triggerPath.Child("targetRatePerReplica"),
+				"required by the throughput scalingPolicy"))
+		case t.TargetRatePerReplica.Sign() <= 0:
+			allErrs = append(allErrs, field.Invalid(triggerPath.Child("targetRatePerReplica"), t.TargetRatePerReplica.String(),
+				"must be positive"))
+		}
+	default:
+		if t.ScaleDownThreshold >= t.ScaleUpThreshold {
+			allErrs = append(allErrs, field.Invalid(triggerPath.Child("scaleDownThreshold"), t.ScaleDownThreshold,
+				fmt.Sprintf("must be less than scaleUpThreshold (%d)", t.ScaleUpThreshold)))
+		}
+	}
+	return allErrs
+}
+
+func validateURL(urlPath *field.Path, raw string, schemes ...string) field.ErrorList {
+	if raw == "" {
+		return field.ErrorList{field.Required(urlPath, "required by the selected natsBackend")}
+	}
+	u, err := url.Parse(raw)
+	switch {
+	case err != nil:
+		return field.ErrorList{field.Invalid(urlPath, raw, err.Error())}
+	case !slices.Contains(schemes, u.Scheme):
+		return field.ErrorList{field.NotSupported(urlPath, u.Scheme, schemes)}
+	case u.Host == "":
+		return field.ErrorList{field.Invalid(urlPath, raw, "must contain a host")}
+	}
+	return nil
+}
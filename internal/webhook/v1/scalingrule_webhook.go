@@ -0,0 +1,201 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	scalingv1 "github.com/Av1shay/nats-scaler/api/v1"
+	"github.com/Av1shay/nats-scaler/internal/validation"
+)
+
+const (
+	defaultPollIntervalSeconds = 30
+	defaultScaleUpThreshold    = 100
+	defaultScaleDownThreshold  = 10
+)
+
+// log is for logging in this package.
+var scalingrulelog = logf.Log.WithName("scalingrule-resource")
+
+// SetupScalingRuleWebhookWithManager registers the webhook for ScalingRule in the manager.
+func SetupScalingRuleWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&scalingv1.ScalingRule{}).
+		WithValidator(&ScalingRuleCustomValidator{Client: mgr.GetClient()}).
+		WithDefaulter(&ScalingRuleCustomDefaulter{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-scaling-my-domain-v1-scalingrule,mutating=true,failurePolicy=fail,sideEffects=None,groups=scaling.my.domain,resources=scalingrules,verbs=create;update,versions=v1,name=mscalingrule-v1.kb.io,admissionReviewVersions=v1
+
+// ScalingRuleCustomDefaulter sets default values on the ScalingRule spec when it is created or updated.
+type ScalingRuleCustomDefaulter struct{}
+
+var _ webhook.CustomDefaulter = &ScalingRuleCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the Kind ScalingRule.
+func (d *ScalingRuleCustomDefaulter) Default(_ context.Context, obj runtime.Object) error {
+	rule, ok := obj.(*scalingv1.ScalingRule)
+	if !ok {
+		return fmt.Errorf("expected a ScalingRule object but got %T", obj)
+	}
+	scalingrulelog.Info("Defaulting for ScalingRule", "name", rule.GetName())
+
+	// migrate the deprecated deploymentName to scaleTargetRef
+	if rule.Spec.ScaleTargetRef == nil && rule.Spec.DeploymentName != "" {
+		rule.Spec.ScaleTargetRef = &scalingv1.ScaleTargetRef{Name: rule.Spec.DeploymentName}
+	}
+	if rule.Spec.ScaleTargetRef != nil {
+		target := rule.Spec.ScaleTarget()
+		rule.Spec.ScaleTargetRef = &target
+	}
+	if rule.Spec.PollIntervalSeconds == 0 {
+		rule.Spec.PollIntervalSeconds = defaultPollIntervalSeconds
+	}
+	// a rule with both thresholds at zero can never pass validation, so treat it as unset
+	if rule.Spec.ScaleUpThreshold == 0 && rule.Spec.ScaleDownThreshold == 0 {
+		rule.Spec.ScaleUpThreshold = defaultScaleUpThreshold
+		rule.Spec.ScaleDownThreshold = defaultScaleDownThreshold
+	}
+
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-scaling-my-domain-v1-scalingrule,mutating=false,failurePolicy=fail,sideEffects=None,groups=scaling.my.domain,resources=scalingrules,verbs=create;update,versions=v1,name=vscalingrule-v1.kb.io,admissionReviewVersions=v1
+
+// ScalingRuleCustomValidator validates ScalingRules when they are created or updated.
+// The Client is used to reject rules targeting a workload that another rule already scales.
+type ScalingRuleCustomValidator struct {
+	Client client.Reader
+}
+
+var _ webhook.CustomValidator = &ScalingRuleCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type ScalingRule.
+func (v *ScalingRuleCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	rule, ok := obj.(*scalingv1.ScalingRule)
+	if !ok {
+		return nil, fmt.Errorf("expected a ScalingRule object but got %T", obj)
+	}
+	scalingrulelog.Info("Validation for ScalingRule upon creation", "name", rule.GetName())
+
+	allErrs := validation.ValidateScalingRuleSpec(rule.Spec, field.NewPath("spec"))
+	dupErrs, err := v.validateUniqueTarget(ctx, rule)
+	if err != nil {
+		return nil, err
+	}
+	allErrs = append(allErrs, dupErrs...)
+
+	return nil, toInvalidErr(rule, allErrs)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type ScalingRule.
+func (v *ScalingRuleCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	rule, ok := newObj.(*scalingv1.ScalingRule)
+	if !ok {
+		return nil, fmt.Errorf("expected a ScalingRule object for the newObj but got %T", newObj)
+	}
+	oldRule, ok := oldObj.(*scalingv1.ScalingRule)
+	if !ok {
+		return nil, fmt.Errorf("expected a ScalingRule object for the oldObj but got %T", oldObj)
+	}
+	scalingrulelog.Info("Validation for ScalingRule upon update", "name", rule.GetName())
+
+	// metadata updates, such as the controller's finalizer, must go through even when the rule was made
+	// invalid by a newer webhook or shares its target with a rule created while the webhook was off
+	if !rule.DeletionTimestamp.IsZero() || equality.Semantic.DeepEqual(oldRule.Spec, rule.Spec) {
+		return nil, nil
+	}
+
+	specPath := field.NewPath("spec")
+	allErrs := validation.ValidateScalingRuleSpec(rule.Spec, specPath)
+	allErrs = append(allErrs, validateImmutableFields(oldRule.Spec, rule.Spec, specPath)...)
+	if oldRule.Spec.ScaleTarget() != rule.Spec.ScaleTarget() || oldRule.Spec.Namespace != rule.Spec.Namespace {
+		dupErrs, err := v.validateUniqueTarget(ctx, rule)
+		if err != nil {
+			return nil, err
+		}
+		allErrs = append(allErrs, dupErrs...)
+	}
+
+	return nil, toInvalidErr(rule, allErrs)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type ScalingRule.
+func (v *ScalingRuleCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func validateImmutableFields(oldSpec, newSpec scalingv1.ScalingRuleSpec, specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if oldSpec.ScaleTarget() != newSpec.ScaleTarget() {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("scaleTargetRef"), "field is immutable"))
+	}
+	if oldSpec.Namespace != newSpec.Namespace {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("namespace"), "field is immutable"))
+	}
+	return allErrs
+}
+
+// validateUniqueTarget makes sure no other ScalingRule already targets the same workload,
+// otherwise the two rules would fight over its replica count.
+func (v *ScalingRuleCustomValidator) validateUniqueTarget(ctx context.Context, rule *scalingv1.ScalingRule) (field.ErrorList, error) {
+	var rules scalingv1.ScalingRuleList
+	if err := v.Client.List(ctx, &rules); err != nil {
+		return nil, fmt.Errorf("failed to list ScalingRules: %w", err)
+	}
+
+	target := rule.Spec.ScaleTarget()
+	var allErrs field.ErrorList
+	for _, other := range rules.Items {
+		if other.Namespace == rule.Namespace && other.Name == rule.Name {
+			continue
+		}
+		if other.Spec.Namespace == rule.Spec.Namespace && sameTarget(other.Spec.ScaleTarget(), target) {
+			allErrs = append(allErrs, field.Duplicate(field.NewPath("spec", "scaleTargetRef"),
+				fmt.Sprintf("%s %s/%s is already scaled by ScalingRule %s/%s",
+					target.Kind, rule.Spec.Namespace, target.Name, other.Namespace, other.Name)))
+		}
+	}
+	return allErrs, nil
+}
+
+// sameTarget compares scale targets by group, kind and name, so apps/v1 and apps/v1beta1 refs collide.
+func sameTarget(a, b scalingv1.ScaleTargetRef) bool {
+	gvA, _ := schema.ParseGroupVersion(a.APIVersion)
+	gvB, _ := schema.ParseGroupVersion(b.APIVersion)
+	return gvA.Group == gvB.Group && a.Kind == b.Kind && a.Name == b.Name
+}
+
+func toInvalidErr(rule *scalingv1.ScalingRule, allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(scalingv1.GroupVersion.WithKind("ScalingRule").GroupKind(), rule.Name, allErrs)
+}
@@ -0,0 +1,370 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	scalingv1 "github.com/Av1shay/nats-scaler/api/v1"
+)
+
+var _ = Describe("ScalingRule Webhook", func() {
+	var (
+		ctx       context.Context
+		obj       *scalingv1.ScalingRule
+		oldObj    *scalingv1.ScalingRule
+		validator ScalingRuleCustomValidator
+		defaulter ScalingRuleCustomDefaulter
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		obj = newScalingRule("rule", "my-deploy")
+		oldObj = newScalingRule("rule", "my-deploy")
+		validator = ScalingRuleCustomValidator{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+		defaulter = ScalingRuleCustomDefaulter{}
+	})
+
+	Context("When creating ScalingRule under Defaulting Webhook", func() {
+		It("Should apply defaults when poll interval and thresholds are omitted", func() {
+			obj.Spec.PollIntervalSeconds = 0
+			obj.Spec.ScaleUpThreshold = 0
+			obj.Spec.ScaleDownThreshold = 0
+
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.PollIntervalSeconds).To(Equal(defaultPollIntervalSeconds))
+			Expect(obj.Spec.ScaleUpThreshold).To(Equal(defaultScaleUpThreshold))
+			Expect(obj.Spec.ScaleDownThreshold).To(Equal(defaultScaleDownThreshold))
+		})
+
+		It("Should migrate deploymentName to scaleTargetRef", func() {
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.ScaleTargetRef).To(Equal(&scalingv1.ScaleTargetRef{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       "my-deploy",
+			}))
+		})
+
+		It("Should keep explicitly set values", func() {
+			obj.Spec.ScaleDownThreshold = 0
+
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.PollIntervalSeconds).To(Equal(10))
+			Expect(obj.Spec.ScaleUpThreshold).To(Equal(10))
+			Expect(obj.Spec.ScaleDownThreshold).To(Equal(0))
+		})
+	})
+
+	Context("When creating or updating ScalingRule under Validating Webhook", func() {
+		It("Should admit a valid rule", func() {
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny if scaleDownThreshold is not below scaleUpThreshold", func() {
+			obj.Spec.ScaleDownThreshold = obj.Spec.ScaleUpThreshold
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.scaleDownThreshold"))
+		})
+
+		It("Should require targetPendingPerReplica with the proportional policy", func() {
+			obj.Spec.ScalingPolicy = scalingv1.ScalingPolicyProportional
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.targetPendingPerReplica"))
+
+			obj.Spec.TargetPendingPerReplica = 100
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should require a positive targetRatePerReplica with the throughput policy", func() {
+			obj.Spec.ScalingPolicy = scalingv1.ScalingPolicyThroughput
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.targetRatePerReplica: Required value"))
+
+			rate := resource.MustParse("0")
+			obj.Spec.TargetRatePerReplica = &rate
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("must be positive"))
+
+			rate = resource.MustParse("2500m")
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny if minReplicas is above maxReplicas", func() {
+			obj.Spec.MinReplicas = 4
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.minReplicas"))
+		})
+
+		It("Should validate each trigger", func() {
+			obj.Spec.Triggers = []scalingv1.ScalingTrigger{{Name: "orders", StreamName: "ORDERS", ConsumerName: "orders"}}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.triggers"))
+
+			obj.Spec.StreamName, obj.Spec.ConsumerName = "", ""
+			obj.Spec.Triggers = append(obj.Spec.Triggers, scalingv1.ScalingTrigger{
+				Name: "payments", StreamName: "PAYMENTS", ConsumerName: "payments", ScaleUpThreshold: 5, ScaleDownThreshold: 10,
+			})
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.triggers[1].scaleDownThreshold"))
+
+			obj.Spec.Triggers[1].ScaleDownThreshold = 1
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should require weights with the weighted metric", func() {
+			obj.Spec.Metric = scalingv1.ScalingMetricWeighted
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.metricWeights"))
+
+			obj.Spec.MetricWeights = &scalingv1.MetricWeights{Pending: 1, AckPending: 2}
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny scaleToZero with a non-zero minReplicas", func() {
+			obj.Spec.ScaleToZero = &scalingv1.ScaleToZero{ActivationThreshold: 1, ActivationReplicas: 2, IdleSeconds: 300}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.minReplicas"))
+
+			obj.Spec.MinReplicas = 0
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should require the replicas of the replicas fallback action", func() {
+			obj.Spec.Fallback = &scalingv1.Fallback{Action: scalingv1.FallbackActionReplicas, FailureThreshold: 3}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.fallback.replicas"))
+
+			replicas := int32(3)
+			obj.Spec.Fallback.Replicas = &replicas
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should validate schedule windows", func() {
+			minReplicas, upThreshold := int32(8), 50
+			obj.Spec.Schedules = []scalingv1.ScheduleWindow{{
+				Name:             "nightly",
+				Start:            "0 22 * * *",
+				DurationSeconds:  3600,
+				TimeZone:         "Mars/Olympus",
+				MinReplicas:      &minReplicas,
+				ScaleUpThreshold: &upThreshold,
+			}}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.schedules[0]: Invalid value"))
+			Expect(err.Error()).To(ContainSubstring("spec.schedules[0].minReplicas"))
+			Expect(err.Error()).To(ContainSubstring("spec.schedules[0].scaleDownThreshold"))
+
+			maxReplicas, downThreshold := int32(10), 5
+			obj.Spec.Schedules[0].TimeZone = "Europe/Berlin"
+			obj.Spec.Schedules[0].MaxReplicas = &maxReplicas
+			obj.Spec.Schedules[0].ScaleDownThreshold = &downThreshold
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should validate the rule with the overrides of its schedule windows", func() {
+			maxReplicas := int32(2)
+			obj.Spec.MinReplicas = 0
+			obj.Spec.ScaleToZero = &scalingv1.ScaleToZero{ActivationReplicas: 3}
+			obj.Spec.Schedules = []scalingv1.ScheduleWindow{{
+				Name:            "nightly",
+				Start:           "0 22 * * *",
+				DurationSeconds: 3600,
+				MaxReplicas:     &maxReplicas,
+			}}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.scaleToZero.activationReplicas"))
+			Expect(err.Error()).To(ContainSubstring("while schedule nightly is open"))
+
+			maxReplicas = 3
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny unsupported URL schemes", func() {
+			obj.Spec.NatsMonitoringURL = "nats://nats:4222"
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.natsMonitoringURL"))
+		})
+
+		It("Should validate every monitoring URL", func() {
+			obj.Spec.NatsMonitoringURL = ""
+			obj.Spec.NatsMonitoringURLs = []string{"http://nats-0:8222", "nats://nats-1:4222"}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.natsMonitoringURLs[1]"))
+
+			obj.Spec.NatsMonitoringURLs[1] = "http://nats-1:8222"
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should require natsURL for the jetstream backend", func() {
+			obj.Spec.NatsBackend = scalingv1.NatsBackendJetStream
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.natsURL"))
+
+			obj.Spec.NatsURL = "nats://nats:4222"
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+
+			obj.Spec.NatsURL = "nats://nats-0:4222,nats://nats-1:4222"
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny inline endpoints together with natsConnectionRef", func() {
+			obj.Spec.NatsConnectionRef = &scalingv1.NatsConnectionReference{Kind: scalingv1.ClusterNatsConnectionKind, Name: "nats"}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.natsMonitoringURL"))
+
+			obj.Spec.NatsMonitoringURL = ""
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should require a scale target", func() {
+			obj.Spec.DeploymentName = ""
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.scaleTargetRef"))
+		})
+
+		It("Should deny a deploymentName that doesn't match scaleTargetRef", func() {
+			obj.Spec.ScaleTargetRef = &scalingv1.ScaleTargetRef{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "my-deploy"}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.deploymentName"))
+
+			obj.Spec.DeploymentName = ""
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny changing the target deployment", func() {
+			obj.Spec.DeploymentName = "other-deploy"
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("field is immutable"))
+		})
+
+		It("Should deny a second rule targeting the same deployment", func() {
+			existing := newScalingRule("existing", "my-deploy")
+			validator.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).Build()
+
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("already scaled by ScalingRule default/existing"))
+		})
+
+		It("Should allow rules targeting workloads of different kinds with the same name", func() {
+			existing := newScalingRule("existing", "my-deploy")
+			validator.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).Build()
+			obj.Spec.DeploymentName = ""
+			obj.Spec.ScaleTargetRef = &scalingv1.ScaleTargetRef{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "my-deploy"}
+
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should not treat the rule being updated as a duplicate of itself", func() {
+			validator.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(oldObj).Build()
+			obj.Spec.MaxReplicas = 5
+
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should admit metadata updates of a rule sharing its target", func() {
+			existing := newScalingRule("existing", "my-deploy")
+			validator.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing, oldObj).Build()
+			obj.Finalizers = []string{"scaling.my.domain/restore-replicas"}
+
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).NotTo(HaveOccurred())
+
+			// spec updates are validated, but the target isn't checked again as it can't change
+			obj.Spec.MaxReplicas = 5
+			_, err = validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).NotTo(HaveOccurred())
+			obj.Spec.MinReplicas = 6
+			_, err = validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).NotTo(ContainSubstring("already scaled"))
+		})
+
+		It("Should admit releasing the finalizer of a deleted invalid rule", func() {
+			oldObj.Spec.ScaleDownThreshold = oldObj.Spec.ScaleUpThreshold
+			oldObj.Finalizers = []string{"scaling.my.domain/restore-replicas"}
+			oldObj.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			obj = oldObj.DeepCopy()
+			obj.Finalizers = nil
+
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
+
+func newScalingRule(name, deployment string) *scalingv1.ScalingRule {
+	return &scalingv1.ScalingRule{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: scalingv1.ScalingRuleSpec{
+			DeploymentName:      deployment,
+			Namespace:           "default",
+			MinReplicas:         1,
+			MaxReplicas:         3,
+			NatsMonitoringURL:   "http://nats:8222",
+			StreamName:          "ORDERS",
+			ConsumerName:        "orders-consumer",
+			ScaleUpThreshold:    10,
+			ScaleDownThreshold:  2,
+			PollIntervalSeconds: 10,
+		},
+	}
+}
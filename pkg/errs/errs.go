@@ -16,3 +16,36 @@ func (e *HTTPStatusCodeErr) Error() string {
 	}
 	return fmt.Sprintf("HTTP Error with status %d: %s", e.Code, bod)
 }
+
+// AccountNotFoundErr is returned when the NATS monitoring endpoint has no details for the requested account.
+type AccountNotFoundErr struct {
+	Account string
+}
+
+func (e *AccountNotFoundErr) Error() string {
+	return fmt.Sprintf("NATS account <%s> not found", e.Account)
+}
+
+// ConsumerNotFoundErr is returned when the requested JetStream stream or consumer doesn't exist.
+// Err holds the underlying error of the backend, if any.
+type ConsumerNotFoundErr struct {
+	Account  string
+	Stream   string
+	Consumer string
+	Err      error
+}
+
+func (e *ConsumerNotFoundErr) Error() string {
+	msg := fmt.Sprintf("couldn't find NATS stream <%s>, consumer <%s>", e.Stream, e.Consumer)
+	if e.Account != "" {
+		msg = fmt.Sprintf("couldn't find NATS account <%s>, stream <%s>, consumer <%s>", e.Account, e.Stream, e.Consumer)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ConsumerNotFoundErr) Unwrap() error {
+	return e.Err
+}